@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flight-tracker-slack/shared"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// adminRouter serves the endpoints meant for whoever runs the bot, each request carrying the admin token
+func adminRouter(config shared.Config) http.Handler {
+	r := chi.NewRouter()
+	r.Use(requireToken(config.AdminToken))
+
+	// undelivered alerts per channel, to spot rate limits and dead messages
+	r.Get("/outbox", func(w http.ResponseWriter, r *http.Request) {
+		backlog, err := shared.GetOutboxBacklog(config)
+		if err != nil {
+			http.Error(w, "Failed to load outbox", http.StatusInternalServerError)
+			return
+		}
+		if backlog == nil {
+			backlog = []shared.OutboxBacklog{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(backlog)
+	})
+
+	return r
+}
+
+// requireToken rejects the requests without "Authorization: Bearer <token>"
+func requireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// Package assets embeds the files the bot reads at startup, so that it doesn't depend on the directory it runs from
+package assets
+
+import "embed"
+
+//go:embed connection_times.json i18n/*.json templates/alerts/*.tmpl planes/*.svg
+var Files embed.FS
@@ -3,6 +3,7 @@ package commands
 import (
 	"bytes"
 	"encoding/json"
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
 	"io"
 	"log"
@@ -17,9 +18,13 @@ func init() {
 	CommandList = []shared.Command{
 		ListCommand,
 		TrackCommand,
+		TripCommand,
 		UntrackCommand,
 		HelpCommand,
 		InfoCommand,
+		SettingsCommand,
+		TemplatesCommand,
+		WebhooksCommand,
 	}
 }
 
@@ -57,76 +62,73 @@ func HandleCommand(name string, w http.ResponseWriter, r *http.Request, config s
 	}
 
 	// run the command in a separate goroutine
-	go func(cmd slack.SlashCommand) {
-		var blocks []slack.Block
-		var inChannel bool = true
-		var after func() error = nil
-
-		for _, command := range CommandList {
-			if command.Name == name {
-				blocks, inChannel, after = command.Execute(s, config)
-				break
-			}
-		}
+	go RunCommand(name, s, config)
+}
+
+// RunCommand runs a slash command and answers through its response url, whatever transport it came from
+func RunCommand(name string, cmd slack.SlashCommand, config shared.Config) {
+	var blocks []slack.Block
+	var inChannel bool = true
+	var after func() error = nil
 
-		var responseType = slack.ResponseTypeInChannel
-		if !inChannel {
-			responseType = slack.ResponseTypeEphemeral
+	for _, command := range CommandList {
+		if command.Name == name {
+			blocks, inChannel, after = command.Execute(cmd, config)
+			break
 		}
+	}
+
+	var responseType = slack.ResponseTypeInChannel
+	if !inChannel {
+		responseType = slack.ResponseTypeEphemeral
+	}
+
+	// print the blocks in json for debugging
+	b, _ := json.MarshalIndent(blocks, "", "  ")
+	log.Printf("Response blocks: %s", string(b))
 
-		// print the blocks in json for debugging
-		b, _ := json.MarshalIndent(blocks, "", "  ")
-		log.Printf("Response blocks: %s", string(b))
+	payload := &slack.WebhookMessage{
+		ResponseType: responseType,
+		Blocks: &slack.Blocks{
+			BlockSet: blocks,
+		},
+	}
 
-		payload := &slack.WebhookMessage{
-			ResponseType: responseType,
+	// commands opening a modal don't need to answer in the channel
+	var err error
+	if len(blocks) > 0 {
+		err = slack.PostWebhook(cmd.ResponseURL, payload)
+	}
+	locale := shared.ResolveLocale(cmd.ChannelID, cmd.UserID, config)
+	if err != nil {
+		log.Printf("failed to post to webhook: %v", err)
+		slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
 			Blocks: &slack.Blocks{
-				BlockSet: blocks,
+				BlockSet: shared.NewErrorBlocks(locale, err),
 			},
-		}
+		})
+	}
 
-		err := slack.PostWebhook(cmd.ResponseURL, payload)
+	if after != nil {
+		err = after()
 		if err != nil {
-			log.Printf("failed to post to webhook: %v", err)
-			slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{
+			log.Printf("after function error: %v", err)
+			err = slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{
 				ResponseType: slack.ResponseTypeEphemeral,
 				Blocks: &slack.Blocks{
-					BlockSet: shared.NewErrorBlocks(err),
+					BlockSet: shared.NewErrorBlocks(locale, err, i18n.T(locale, "error.command_failed")),
 				},
 			})
-		}
-
-		if after != nil {
-			err = after()
 			if err != nil {
-				log.Printf("after function error: %v", err)
-				err = slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{
+				slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{
 					ResponseType: slack.ResponseTypeEphemeral,
 					Blocks: &slack.Blocks{
-						BlockSet: []slack.Block{
-							slack.NewSectionBlock(
-								slack.NewTextBlockObject(slack.MarkdownType, "Failed to execute the command entirely :x:", false, false),
-								nil,
-								nil,
-							),
-							slack.NewSectionBlock(
-								slack.NewTextBlockObject(slack.MarkdownType, "_Error details:_ ```"+err.Error()+"```", false, false),
-								nil,
-								nil,
-							),
-						},
+						BlockSet: shared.NewErrorBlocks(locale, err),
 					},
 				})
-				if err != nil {
-					slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{
-						ResponseType: slack.ResponseTypeEphemeral,
-						Blocks: &slack.Blocks{
-							BlockSet: shared.NewErrorBlocks(err),
-						},
-					})
-					log.Printf("failed to post error message to webhook: %v", err)
-				}
+				log.Printf("failed to post error message to webhook: %v", err)
 			}
 		}
-	}(s)
+	}
 }
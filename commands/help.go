@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
 	"strings"
 
@@ -18,6 +19,7 @@ var HelpCommand = shared.Command{
 func Help(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
 	var specificCommand *shared.Command
 	var helpBlocks []slack.Block
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
 
 	args, err := shlex.Split(slashCommand.Text)
 	if err == nil && len(args) >= 1 {
@@ -32,8 +34,8 @@ func Help(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block,
 
 	if specificCommand != nil {
 		helpText := "*/" + specificCommand.Name + "*\n" +
-			specificCommand.Description + "\n" +
-			"*Usage:* `" + specificCommand.Usage + "`"
+			commandDescription(locale, *specificCommand) + "\n" +
+			i18n.T(locale, "help.usage", commandUsage(locale, *specificCommand))
 		helpBlocks = append(helpBlocks, slack.NewSectionBlock(
 			slack.NewTextBlockObject(slack.MarkdownType, helpText, false, false),
 			nil,
@@ -41,11 +43,11 @@ func Help(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block,
 		))
 	} else {
 		var helpText strings.Builder
-		helpText.WriteString("*Available Commands:*\n")
+		helpText.WriteString(i18n.T(locale, "help.title") + "\n")
 		for _, cmd := range CommandList {
-			helpText.WriteString("• */" + cmd.Name + "*: " + cmd.Description + "\n")
+			helpText.WriteString("• */" + cmd.Name + "*: " + commandDescription(locale, cmd) + "\n")
 		}
-		helpText.WriteString("\nType `/help [command_name]` for detailed info on a specific command.")
+		helpText.WriteString("\n" + i18n.T(locale, "help.details"))
 		helpBlocks = append(helpBlocks, slack.NewSectionBlock(
 			slack.NewTextBlockObject(slack.MarkdownType, helpText.String(), false, false),
 			nil,
@@ -55,3 +57,18 @@ func Help(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block,
 
 	return helpBlocks, false, nil
 }
+
+// commandDescription returns the translated description of a command, or the english one from CommandList
+func commandDescription(locale string, cmd shared.Command) string {
+	if msg, ok := i18n.Lookup(locale, "command."+cmd.Name+".description"); ok {
+		return msg
+	}
+	return cmd.Description
+}
+
+func commandUsage(locale string, cmd shared.Command) string {
+	if msg, ok := i18n.Lookup(locale, "command."+cmd.Name+".usage"); ok {
+		return msg
+	}
+	return cmd.Usage
+}
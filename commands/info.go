@@ -3,6 +3,7 @@ package commands
 import (
 	"bytes"
 	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/maps"
 	"flight-tracker-slack/shared"
 	"fmt"
@@ -22,12 +23,13 @@ var InfoCommand = shared.Command{
 }
 
 func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
 
 	blocks := []slack.Block{}
 	instantBlocks := []slack.Block{}
 
 	instantBlocks = append(instantBlocks, slack.NewSectionBlock(
-		slack.NewTextBlockObject(slack.MarkdownType, ":mag_right: I'm on it! (generating the map...)", false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "info.loading"), false, false),
 		nil,
 		nil,
 	))
@@ -36,7 +38,7 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 	if err != nil || len(args) < 1 {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "Usage: `/flight-info [flight_number]`", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "error.usage", i18n.T(locale, "command.flight-info.usage")), false, false),
 				nil,
 				nil,
 			),
@@ -48,12 +50,12 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 	if !flights.FlightNumPattern.MatchString(flightNumber) {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "Doesn't look like a valid flight number... :pensive:", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "flight.invalid"), false, false),
 				nil,
 				nil,
 			),
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "_Flight numbers usually look like `AA100` or `DLH400`._", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "flight.invalid_hint"), false, false),
 				nil,
 				nil,
 			),
@@ -62,7 +64,7 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 
 	flightInfo, err := flights.GetFlightInfo(flightNumber)
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return shared.NewErrorBlocks(locale, err), false, nil
 	}
 
 	var fd flights.FlightDetail
@@ -74,7 +76,7 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 	if fd.Origin.Iata == "" {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "No active flight found for that flight number :pensive:", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "info.not_found"), false, false),
 				nil,
 				nil,
 			),
@@ -84,18 +86,7 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 	image, err := maps.GenerateMapFromFlightDetail(config.TileStore, fd)
 
 	if err != nil {
-		return []slack.Block{
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "We were unable to generate the flight map :x:", false, false),
-				nil,
-				nil,
-			),
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("_Error details:_ ```%v```", err), false, false),
-				nil,
-				nil,
-			),
-		}, false, nil
+		return shared.NewErrorBlocks(locale, err, i18n.T(locale, "info.map_error")), false, nil
 	}
 
 	after := func() error {
@@ -110,7 +101,7 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 			Filename: "flight_map.png",
 			Reader:   &buf,
 			FileSize: buf.Len(),
-			Title:    fmt.Sprintf("%s - %s", flightNumber, time.Now().Format("2006-01-02")),
+			Title:    fmt.Sprintf("%s - %s", flightNumber, i18n.FormatDate(locale, time.Now())),
 			Blocks: slack.Blocks{
 				BlockSet: blocks,
 			},
@@ -122,6 +113,38 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 		return nil
 	}
 
+	infoText, err := FlightSummary(locale, fd)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err), false, nil
+	}
+
+	headerText := i18n.T(locale, "info.header", flightNumber, i18n.FormatDate(locale, time.Now()))
+	blocks = append(blocks, slack.NewHeaderBlock(
+		slack.NewTextBlockObject(slack.PlainTextType, headerText, false, false),
+	))
+
+	blocks = append(blocks, slack.NewSectionBlock(
+		slack.NewTextBlockObject(slack.MarkdownType, infoText, false, false),
+		nil,
+		slack.NewAccessory(
+			slack.NewImageBlockElement(
+				"https://www.flightaware.com/images/airline_logos/180px/"+fd.Airline.Icao+".png",
+				fd.Airline.FullName,
+			),
+		),
+	))
+
+	blocks = append(blocks, slack.NewDividerBlock())
+
+	blocks = append(blocks, slack.NewContextBlock("",
+		slack.NewTextBlockObject(slack.MarkdownType, fd.Aircraft.FriendlyType, false, false),
+	))
+
+	return instantBlocks, false, after
+}
+
+// FlightSummary describes a flight: route, times in the airports' and the reader's time, altitude, speed, gates, terminals, baggage belt and status
+func FlightSummary(locale string, fd flights.FlightDetail) (string, error) {
 	origin := fd.Origin.FriendlyLocation
 	destination := fd.Destination.FriendlyLocation
 
@@ -132,44 +155,39 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 	arrivalTimezone := strings.TrimPrefix(fd.Destination.TZ, ":")
 	depLoc, err := time.LoadLocation(departureTimezone)
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return "", err
 	}
 	arrLoc, err := time.LoadLocation(arrivalTimezone)
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return "", err
 	}
 
 	schedule := fd.GetSchedule()
-	departureScheduled := schedule.DepartureScheduled.In(depLoc).Format("15:04")
-	departureActual := schedule.DepartureActual.In(depLoc).Format("15:04")
-	departureEstimated := schedule.DepartureEstimated.In(depLoc).Format("15:04")
-	arrivalScheduled := schedule.ArrivalScheduled.In(arrLoc).Format("15:04")
-	arrivalEstimated := schedule.ArrivalEstimated.In(arrLoc).Format("15:04")
-	arrivalActual := schedule.ArrivalActual.In(arrLoc).Format("15:04")
+	departureScheduled := shared.FormatAirportTime(locale, schedule.DepartureScheduled, depLoc)
+	departureActual := shared.FormatAirportTime(locale, schedule.DepartureActual, depLoc)
+	departureEstimated := shared.FormatAirportTime(locale, schedule.DepartureEstimated, depLoc)
+	arrivalScheduled := shared.FormatAirportTime(locale, schedule.ArrivalScheduled, arrLoc)
+	arrivalEstimated := shared.FormatAirportTime(locale, schedule.ArrivalEstimated, arrLoc)
+	arrivalActual := shared.FormatAirportTime(locale, schedule.ArrivalActual, arrLoc)
 
 	departureMsg := ""
 	if schedule.DepartureActual.IsZero() && schedule.DepartureEstimated.IsZero() {
-		departureMsg = departureScheduled + " (scheduled)"
+		departureMsg = i18n.T(locale, "info.scheduled", departureScheduled)
 	} else if schedule.DepartureActual.IsZero() && !schedule.DepartureEstimated.IsZero() {
-		departureMsg = departureScheduled + " (estimated at " + departureEstimated + ")"
+		departureMsg = i18n.T(locale, "info.estimated", departureScheduled, departureEstimated)
 	} else {
-		departureMsg = departureActual + " (was scheduled at " + departureScheduled + ")"
+		departureMsg = i18n.T(locale, "info.actual", departureActual, departureScheduled)
 	}
 
 	arrivalMsg := ""
 	if schedule.ArrivalActual.IsZero() && schedule.ArrivalEstimated.IsZero() {
-		arrivalMsg = arrivalScheduled + " (scheduled)"
+		arrivalMsg = i18n.T(locale, "info.scheduled", arrivalScheduled)
 	} else if schedule.ArrivalActual.IsZero() && !schedule.ArrivalEstimated.IsZero() {
-		arrivalMsg = arrivalScheduled + " (estimated at " + arrivalEstimated + ")"
+		arrivalMsg = i18n.T(locale, "info.estimated", arrivalScheduled, arrivalEstimated)
 	} else {
-		arrivalMsg = arrivalActual + " (was scheduled at " + arrivalScheduled + ")"
+		arrivalMsg = i18n.T(locale, "info.actual", arrivalActual, arrivalScheduled)
 	}
 
-	headerText := "Flight " + flightNumber + " - " + time.Now().Format("2006-01-02")
-	blocks = append(blocks, slack.NewHeaderBlock(
-		slack.NewTextBlockObject(slack.PlainTextType, headerText, false, false),
-	))
-
 	gateText := ""
 	if fd.Origin.Gate == "" && fd.Destination.Gate == "" {
 		gateText = ""
@@ -183,40 +201,42 @@ func FlightInfo(slashCommand slack.SlashCommand, config shared.Config) ([]slack.
 			fd.Destination.Gate = "N/A"
 		}
 
-		gateText +=
-			"*Gate:* " + fmt.Sprintf("%s → %s", fd.Origin.Gate, fd.Destination.Gate)
+		gateText += i18n.T(locale, "info.gate", fd.Origin.Gate, fd.Destination.Gate)
+	}
+
+	if fd.Origin.Terminal != "" || fd.Destination.Terminal != "" {
+		if gateText != "" {
+			gateText += "\n"
+		}
+		gateText += i18n.T(locale, "info.terminal", valueOrNA(fd.Origin.Terminal), valueOrNA(fd.Destination.Terminal))
+	}
+	if fd.Destination.BaggageClaim() != "" {
+		if gateText != "" {
+			gateText += "\n"
+		}
+		gateText += i18n.T(locale, "info.baggage", fd.Destination.BaggageClaim())
 	}
 
 	flightStatusText := ""
 	if fd.FlightStatus != "" {
-		flightStatusText = "\n\n_Flight status: " + fd.FlightStatus + "_"
+		flightStatusText = "\n\n" + i18n.T(locale, "info.status", fd.FlightStatus)
 	}
 
-	infoText := "*From:* " + origin + "\n" +
-		"*To:* " + destination + "\n" +
-		"*Departure:* " + departureMsg + "\n" +
-		"*Arrival:* " + arrivalMsg + "\n" +
-		"*Altitude:* " + fmt.Sprintf("%d00 ft", altitude) + "\n" +
-		"*Speed:* " + fmt.Sprintf("%d knots", speed) + "\n" +
+	infoText := i18n.T(locale, "info.from", origin) + "\n" +
+		i18n.T(locale, "info.to", destination) + "\n" +
+		i18n.T(locale, "info.departure", departureMsg) + "\n" +
+		i18n.T(locale, "info.arrival", arrivalMsg) + "\n" +
+		i18n.T(locale, "info.altitude", altitude) + "\n" +
+		i18n.T(locale, "info.speed", speed) + "\n" +
 		gateText +
 		flightStatusText
 
-	blocks = append(blocks, slack.NewSectionBlock(
-		slack.NewTextBlockObject(slack.MarkdownType, infoText, false, false),
-		nil,
-		slack.NewAccessory(
-			slack.NewImageBlockElement(
-				"https://www.flightaware.com/images/airline_logos/180px/"+fd.Airline.Icao+".png",
-				fd.Airline.FullName,
-			),
-		),
-	))
-
-	blocks = append(blocks, slack.NewDividerBlock())
-
-	blocks = append(blocks, slack.NewContextBlock("",
-		slack.NewTextBlockObject(slack.MarkdownType, fd.Aircraft.FriendlyType, false, false),
-	))
+	return infoText, nil
+}
 
-	return instantBlocks, false, after
+func valueOrNA(value string) string {
+	if value == "" {
+		return "N/A"
+	}
+	return value
 }
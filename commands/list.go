@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
 	"time"
 
@@ -15,17 +16,18 @@ var ListCommand = shared.Command{
 }
 
 func List(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
-	var filter = shared.FlightFilter{
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
+	var filter = shared.SubscriptionFilter{
 		SlackUserID: slashCommand.UserID,
 	}
-	var flightData, err = shared.GetFlights(filter, config)
+	var flightData, err = shared.GetSubscriptions(filter, config)
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return shared.NewErrorBlocks(locale, err), false, nil
 	}
 	if len(flightData) == 0 {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "You don't have any tracked flights yet. Use `/track-flight` to start tracking!", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "list.empty"), false, false),
 				nil,
 				nil,
 			),
@@ -35,17 +37,18 @@ func List(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block,
 	var blocks []slack.Block
 	for _, flight := range flightData {
 		blocks = append(blocks, slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, "• *"+flight.FlightNumber+"* in channel <#"+flight.SlackChannel+">, departing in "+shared.FormatDuration(time.Until(time.Unix(flight.Departure, 0))), false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "list.flight", flight.FlightNumber, travelerText(locale, flight), flight.Destination(), i18n.FormatDuration(locale, time.Until(time.Unix(flight.Departure, 0)))), false, false),
 			nil,
-			slack.NewAccessory(
-				slack.NewButtonBlockElement(
-					"untrack-"+flight.FlightNumber+"-"+flight.SlackChannel,
-					"",
-					slack.NewTextBlockObject(slack.PlainTextType, "Untrack", false, false),
-				).WithStyle(slack.StyleDanger),
-			),
+			slack.NewAccessory(UntrackButton(locale, flight, config)),
 		))
 	}
 
 	return blocks, false, nil
 }
+
+func travelerText(locale string, sub shared.Subscription) string {
+	if sub.TravelerID == "" {
+		return ""
+	}
+	return i18n.T(locale, "list.traveler", sub.TravelerID)
+}
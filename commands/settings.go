@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"strconv"
+	"time"
+
+	"github.com/google/shlex"
+	"github.com/slack-go/slack"
+)
+
+var SettingsCommand = shared.Command{
+	Name:        "flight-settings",
+	Description: "Choose which flight alerts are sent here (or to you)",
+	Usage:       "/flight-settings [me (optional)]",
+	Execute:     Settings,
+}
+
+// catalog keys of the category labels
+var alertCategoryLabels = []struct {
+	Category string
+	Label    string
+}{
+	{shared.AlertCategoryGate, "settings.category.gate"},
+	{shared.AlertCategoryDelay, "settings.category.delay"},
+	{shared.AlertCategoryTakeoff, "settings.category.takeoff"},
+	{shared.AlertCategoryLanding, "settings.category.landing"},
+	{shared.AlertCategoryInFlight, "settings.category.in_flight"},
+}
+
+var delayThresholdOptions = []int64{5 * 60, 10 * 60, 15 * 60, 30 * 60, 60 * 60}
+var updateIntervalOptions = []int64{30 * 60, 60 * 60, 2 * 60 * 60, 4 * 60 * 60}
+
+func Settings(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
+
+	// channel settings by default, user settings with "me"
+	scopeID := slashCommand.ChannelID
+	scopeText := "<#" + slashCommand.ChannelID + ">"
+
+	args, err := shlex.Split(slashCommand.Text)
+	if err == nil && len(args) >= 1 && args[0] == "me" {
+		scopeID = slashCommand.UserID
+		scopeText = i18n.T(locale, "settings.scope_me")
+	}
+
+	prefs, err := shared.GetPreferencesOrDefault(scopeID, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err), false, nil
+	}
+
+	_, err = config.SlackClient.OpenView(slashCommand.TriggerID, SettingsModal(locale, prefs, scopeText, slashCommand.ChannelID))
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err, i18n.T(locale, "settings.open_error")), false, nil
+	}
+
+	return nil, false, nil
+}
+
+// SettingsModal builds the modal used to edit the preferences of a channel or a user
+func SettingsModal(locale string, prefs shared.Preferences, scopeText string, channelID string) slack.ModalViewRequest {
+	var options []*slack.OptionBlockObject
+	var initialOptions []*slack.OptionBlockObject
+	for _, c := range alertCategoryLabels {
+		option := slack.NewOptionBlockObject(c.Category, slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, c.Label), false, false), nil)
+		options = append(options, option)
+		if prefs.Allows(c.Category) {
+			initialOptions = append(initialOptions, option)
+		}
+	}
+	checkboxes := slack.NewCheckboxGroupsBlockElement("flightsettings-categories", options...)
+	checkboxes.InitialOptions = initialOptions
+
+	alertsInput := slack.NewInputBlock(
+		"flightsettings-categories",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.alerts"), false, false),
+		nil,
+		checkboxes,
+	)
+	alertsInput.Optional = true
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      "flightsettings-submit",
+		PrivateMetadata: prefs.ScopeID + "|" + channelID,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.title"), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "modal.save"), false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "modal.cancel"), false, false),
+		Blocks: slack.Blocks{
+			BlockSet: []slack.Block{
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "settings.scope", scopeText), false, false),
+					nil,
+					nil,
+				),
+				alertsInput,
+				slack.NewInputBlock(
+					"flightsettings-delaythreshold",
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.delay_threshold"), false, false),
+					nil,
+					durationSelect(locale, "flightsettings-delaythreshold", delayThresholdOptions, prefs.DelayThreshold),
+				),
+				slack.NewInputBlock(
+					"flightsettings-updateinterval",
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.update_interval"), false, false),
+					nil,
+					durationSelect(locale, "flightsettings-updateinterval", updateIntervalOptions, prefs.UpdateInterval),
+				),
+				slack.NewInputBlock(
+					"flightsettings-quietstart",
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.quiet_start"), false, false),
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.quiet_start_hint"), false, false),
+					hourSelect(locale, "flightsettings-quietstart", prefs.QuietStart, i18n.T(locale, "settings.quiet_off")),
+				),
+				slack.NewInputBlock(
+					"flightsettings-quietend",
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.quiet_end"), false, false),
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.quiet_end_hint"), false, false),
+					hourSelect(locale, "flightsettings-quietend", prefs.QuietEnd, i18n.T(locale, "settings.quiet_off")),
+				),
+				slack.NewInputBlock(
+					"flightsettings-locale",
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.language"), false, false),
+					nil,
+					localeSelect(locale, "flightsettings-locale", prefs.Locale),
+				),
+			},
+		},
+	}
+}
+
+func durationSelect(locale string, actionID string, values []int64, selected int64) *slack.SelectBlockElement {
+	var options []*slack.OptionBlockObject
+	var initial *slack.OptionBlockObject
+	for _, v := range values {
+		option := slack.NewOptionBlockObject(
+			strconv.FormatInt(v, 10),
+			slack.NewTextBlockObject(slack.PlainTextType, i18n.FormatDuration(locale, time.Duration(v)*time.Second), false, false),
+			nil,
+		)
+		options = append(options, option)
+		if v == selected {
+			initial = option
+		}
+	}
+
+	// keep a custom value that isn't part of the list
+	if initial == nil {
+		initial = slack.NewOptionBlockObject(
+			strconv.FormatInt(selected, 10),
+			slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.current", i18n.FormatDuration(locale, time.Duration(selected)*time.Second)), false, false),
+			nil,
+		)
+		options = append(options, initial)
+	}
+
+	return slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, actionID, options...).WithInitialOption(initial)
+}
+
+// hourSelect lists every hour of the day, values being minutes after midnight
+func hourSelect(locale string, actionID string, selected int, offLabel string) *slack.SelectBlockElement {
+	off := slack.NewOptionBlockObject("-1", slack.NewTextBlockObject(slack.PlainTextType, offLabel, false, false), nil)
+	options := []*slack.OptionBlockObject{off}
+	initial := off
+	for h := 0; h < 24; h++ {
+		option := slack.NewOptionBlockObject(
+			strconv.Itoa(h*60),
+			slack.NewTextBlockObject(slack.PlainTextType, i18n.FormatTime(locale, time.Date(2000, 1, 1, h, 0, 0, 0, time.UTC)), false, false),
+			nil,
+		)
+		options = append(options, option)
+		if h*60 == selected {
+			initial = option
+		}
+	}
+
+	return slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, actionID, options...).WithInitialOption(initial)
+}
+
+// localeSelect lists the supported languages, each one written in its own language
+func localeSelect(locale string, actionID string, selected string) *slack.SelectBlockElement {
+	auto := slack.NewOptionBlockObject("auto", slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "settings.language_auto"), false, false), nil)
+	options := []*slack.OptionBlockObject{auto}
+	initial := auto
+	for _, l := range i18n.Locales {
+		option := slack.NewOptionBlockObject(l, slack.NewTextBlockObject(slack.PlainTextType, i18n.LanguageName(l), false, false), nil)
+		options = append(options, option)
+		if l == selected {
+			initial = option
+		}
+	}
+
+	return slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, actionID, options...).WithInitialOption(initial)
+}
@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"flight-tracker-slack/templates"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+	"github.com/slack-go/slack"
+)
+
+var TemplatesCommand = shared.Command{
+	Name:        "flight-templates",
+	Description: "Preview and customize the alert messages",
+	Usage:       "/flight-templates [preview|edit|reset] [alert_type (optional for preview)] [workspace (optional)]",
+	Execute:     Templates,
+}
+
+func Templates(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
+
+	args, err := shlex.Split(slashCommand.Text)
+	if err != nil || len(args) < 1 {
+		return templatesUsage(locale), false, nil
+	}
+
+	action := strings.ToLower(args[0])
+	alertType := ""
+	if len(args) >= 2 {
+		alertType = args[1]
+		if !templates.IsAlertType(alertType) {
+			return []slack.Block{
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "templates.unknown_type", strings.Join(templates.AlertTypes, "`, `")), false, false),
+					nil,
+					nil,
+				),
+			}, false, nil
+		}
+	}
+
+	// channel templates by default, workspace ones with "workspace"
+	scopeID := slashCommand.ChannelID
+	scopeText := "<#" + slashCommand.ChannelID + ">"
+	if len(args) >= 3 && strings.ToLower(args[2]) == "workspace" {
+		scopeID = shared.WorkspaceScope
+		scopeText = i18n.T(locale, "templates.scope_workspace")
+	}
+
+	switch action {
+	case "preview":
+		messages := previewTemplates(locale, alertType, slashCommand.ChannelID, config)
+		if len(messages) == 1 {
+			return messages[0], false, nil
+		}
+		// the preview of every alert doesn't fit in one message, the others follow the answer
+		after := func() error {
+			for _, blocks := range messages[1:] {
+				if err := answerEphemeral(slashCommand, blocks, config); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return messages[0], false, after
+	case "edit", "reset":
+		if alertType == "" {
+			return templatesUsage(locale), false, nil
+		}
+		if scopeID == shared.WorkspaceScope {
+			admin, err := isWorkspaceAdmin(slashCommand.UserID, config)
+			if err != nil {
+				return shared.NewErrorBlocks(locale, err), false, nil
+			}
+			if !admin {
+				return []slack.Block{
+					slack.NewSectionBlock(
+						slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "templates.admin_only"), false, false),
+						nil,
+						nil,
+					),
+				}, false, nil
+			}
+		}
+
+		if action == "reset" {
+			if err := shared.DeleteAlertTemplate(scopeID, alertType, config); err != nil {
+				return shared.NewErrorBlocks(locale, err), false, nil
+			}
+			return []slack.Block{
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "templates.reset", alertType, scopeText), false, false),
+					nil,
+					nil,
+				),
+			}, false, nil
+		}
+
+		body := templates.DefaultBody(alertType)
+		override, err := shared.GetAlertTemplate(scopeID, alertType, config)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return shared.NewErrorBlocks(locale, err), false, nil
+		}
+		if override != nil {
+			body = override.Body
+		}
+
+		_, err = config.SlackClient.OpenView(slashCommand.TriggerID, TemplateModal(locale, alertType, body, scopeID, scopeText, slashCommand.ChannelID))
+		if err != nil {
+			return shared.NewErrorBlocks(locale, err, i18n.T(locale, "templates.open_error")), false, nil
+		}
+		return nil, false, nil
+	}
+
+	return templatesUsage(locale), false, nil
+}
+
+func templatesUsage(locale string) []slack.Block {
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "error.usage", i18n.T(locale, "command.flight-templates.usage")), false, false),
+			nil,
+			nil,
+		),
+	}
+}
+
+// previewTemplates renders every alert (or a single one) against sample data, as they would be sent in the channel.
+// It returns as many messages as needed to stay under the blocks limit of slack.
+func previewTemplates(locale string, alertType string, channelID string, config shared.Config) [][]slack.Block {
+	alertTypes := templates.AlertTypes
+	if alertType != "" {
+		alertTypes = []string{alertType}
+	}
+
+	messages := [][]slack.Block{{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "templates.preview"), false, false),
+			nil,
+			nil,
+		),
+	}}
+	for _, t := range alertTypes {
+		data := templates.SampleDataFor(t, time.Now())
+		data.Locale = locale
+		rendered, err := templates.Render(t, channelID, data, config)
+		blocks := []slack.Block{slack.NewDividerBlock(), slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, "`"+t+"`", false, false),
+		)}
+		if err != nil {
+			blocks = append(blocks, slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "error.details", err), false, false),
+				nil,
+				nil,
+			))
+		} else {
+			blocks = append(blocks, rendered...)
+		}
+
+		// an alert is never split between two messages
+		last := len(messages) - 1
+		if len(messages[last])+len(blocks) > shared.MaxBlocksPerMessage {
+			messages = append(messages, nil)
+			last++
+		}
+		messages[last] = append(messages[last], blocks...)
+	}
+	return messages
+}
+
+// answerEphemeral sends another answer to a command, through its response url
+// or, for mentions which don't have one, as an ephemeral message
+func answerEphemeral(slashCommand slack.SlashCommand, blocks []slack.Block, config shared.Config) error {
+	if slashCommand.ResponseURL != "" {
+		return slack.PostWebhook(slashCommand.ResponseURL, &slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Blocks:       &slack.Blocks{BlockSet: blocks},
+		})
+	}
+	_, err := config.SlackClient.PostEphemeral(slashCommand.ChannelID, slashCommand.UserID, slack.MsgOptionBlocks(blocks...))
+	return err
+}
+
+// TemplateModal builds the modal used to edit the template of an alert type
+func TemplateModal(locale string, alertType string, body string, scopeID string, scopeText string, channelID string) slack.ModalViewRequest {
+	input := slack.NewPlainTextInputBlockElement(nil, "flighttemplates-body")
+	input.Multiline = true
+	input.InitialValue = body
+	input.MaxLength = 3000
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      "flighttemplates-submit",
+		PrivateMetadata: scopeID + "|" + alertType + "|" + channelID,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "templates.title"), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "modal.save"), false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "modal.cancel"), false, false),
+		Blocks: slack.Blocks{
+			BlockSet: []slack.Block{
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "templates.modal_intro", alertType, scopeText), false, false),
+					nil,
+					nil,
+				),
+				slack.NewInputBlock(
+					"flighttemplates-body",
+					slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "templates.input"), false, false),
+					nil,
+					input,
+				),
+			},
+		},
+	}
+}
+
+// isWorkspaceAdmin returns whether a user can change the settings of the whole workspace
+func isWorkspaceAdmin(userID string, config shared.Config) (bool, error) {
+	user, err := config.SlackClient.GetUserInfo(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin || user.IsOwner, nil
+}
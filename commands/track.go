@@ -1,8 +1,14 @@
 package commands
 
 import (
+	"errors"
 	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
+	"log"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/shlex"
 	"github.com/slack-go/slack"
@@ -11,115 +17,457 @@ import (
 var TrackCommand = shared.Command{
 	Name:        "track-flight",
 	Description: "Track a flight",
-	Usage:       "/track-flight [flight_number (iata or icao)]",
+	Usage:       "/track-flight [flight_number (optional)] [today|tomorrow|YYYY-MM-DD (optional)] [for @someone (optional)] [dm (optional)]",
 	Execute:     Track,
 }
 
-func Track(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
-
-	// first check if the bot is in the channel, and it's not a dm
+// matches user mentions like <@U123ABC> or <@U123ABC|alice>, as sent when the command escapes users
+var userMentionPattern = regexp.MustCompile(`^<@([UW][A-Z0-9]+)(\|[^>]*)?>$`)
 
-	isInChannel, err := config.SlackClient.GetConversationInfo(&slack.GetConversationInfoInput{
-		ChannelID:         slashCommand.ChannelID,
-		IncludeLocale:     false,
-		IncludeNumMembers: false,
-	})
-	if err != nil || isInChannel.IsMember == false && isInChannel.IsOpen == false {
-		return shared.NewErrorBlocks(err, ":warning: I need to be in this channel to track flights here! "), false, nil
-	}
+// Track opens the tracking modal, prefilled with the arguments of the command.
+// Without a trigger id (e.g. from a mention) it answers with a button opening it.
+func Track(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
 
 	args, err := shlex.Split(slashCommand.Text)
-	if err != nil || len(args) < 1 {
+	if err != nil {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "This didn't work :pensive: \n _Usage: `/track [flight_number (iata or icao)]`_", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "error.usage", i18n.T(locale, "command.track-flight.usage")), false, false),
 				nil,
 				nil,
 			),
 		}, false, nil
 	}
 
-	flightNumber := args[0]
+	var request shared.TrackRequest
+
+	// optional arguments: the flight number, the departure date, "for @someone" and "dm"
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "dm":
+			request.DM = true
+		case "today":
+			request.Date = userNow(slashCommand.UserID, config).Format(time.DateOnly)
+		case "tomorrow":
+			request.Date = userNow(slashCommand.UserID, config).AddDate(0, 0, 1).Format(time.DateOnly)
+		case "for":
+			if i+1 < len(args) {
+				if matches := userMentionPattern.FindStringSubmatch(args[i+1]); matches != nil {
+					request.TravelerID = matches[1]
+					i++
+					continue
+				}
+			}
+			return []slack.Block{
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "track.who"), false, false),
+					nil,
+					nil,
+				),
+			}, false, nil
+		default:
+			if d, err := time.Parse(time.DateOnly, args[i]); err == nil {
+				request.Date = d.Format(time.DateOnly)
+			} else if request.FlightNumber == "" {
+				request.FlightNumber = strings.ToUpper(args[i])
+			}
+		}
+	}
 
-	if !flights.FlightNumPattern.MatchString(flightNumber) {
+	if request.FlightNumber != "" && !flights.FlightNumPattern.MatchString(request.FlightNumber) {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "Doesn't look like a valid flight number... :pensive:", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "flight.invalid"), false, false),
 				nil,
 				nil,
 			),
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "_Flight numbers usually look like `AA100` or `DLH400`._", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "flight.invalid_hint"), false, false),
 				nil,
 				nil,
 			),
 		}, false, nil
 	}
 
-	flightsInfo, err := flights.GetFlightInfo(flightNumber)
+	// with the flight and the day, the departure is looked up in the schedule,
+	// the modal only asking when the flight has several legs that day
+	if request.FlightNumber != "" && request.Date != "" {
+		return trackOnDate(locale, slashCommand, request, config), false, nil
+	}
+
+	if slashCommand.TriggerID == "" {
+		return trackButton(locale, request, config), false, nil
+	}
+
+	// the trigger id expires after 3 seconds, so the modal is opened before loading the schedule
+	status := ""
+	if request.FlightNumber != "" {
+		status = i18n.T(locale, "track.loading", request.FlightNumber)
+	}
+	view, err := config.SlackClient.OpenView(slashCommand.TriggerID, TrackModal(locale, slashCommand.ChannelID, request, "", nil, status))
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return shared.NewErrorBlocks(locale, err), false, nil
+	}
+	if request.FlightNumber == "" {
+		return nil, false, nil
 	}
-	if flightsInfo.GetFirstFlight().Airline.FullName == "" {
+
+	after := func() error {
+		modal := LoadTrackModal(locale, slashCommand.ChannelID, request)
+		_, err := config.SlackClient.UpdateView(modal, "", view.Hash, view.ID)
+		return err
+	}
+	return nil, false, after
+}
+
+// trackOnDate tracks the flight departing on the requested day, or answers with a button opening the modal
+func trackOnDate(locale string, slashCommand slack.SlashCommand, request shared.TrackRequest, config shared.Config) []slack.Block {
+	date, err := time.Parse(time.DateOnly, request.Date)
+	if err != nil {
+		return trackButton(locale, request, config)
+	}
+
+	flightsInfo, err := flights.GetFlightInfo(request.FlightNumber)
+	if err != nil {
+		log.Printf("Error fetching flight info for %s: %v\n", request.FlightNumber, err)
+		return shared.NewErrorBlocks(locale, errors.New(i18n.T(locale, "track.fetch_error", request.FlightNumber)))
+	}
+
+	legs := flightsInfo.LegsOn(date)
+	switch {
+	case len(legs) == 0:
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "Hmm... I couldn't find any flight with that number :pensive:", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "track.not_found")+"\n"+i18n.T(locale, "track.not_found_hint"), false, false),
 				nil,
 				nil,
 			),
+		}
+	case len(legs) > 1:
+		return append([]slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "_Please double-check the flight number and try again._", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "track.several_legs", request.FlightNumber, i18n.FormatDate(locale, date)), false, false),
 				nil,
 				nil,
 			),
-		}, false, nil
+		}, trackButton(locale, request, config)...)
 	}
 
-	// now return a datepicker
+	departure := legs[0].On(date)
+	if time.Since(departure) > 24*time.Hour {
+		return []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "track.date_past"), false, false),
+				nil,
+				nil,
+			),
+		}
+	}
 
-	flight := flightsInfo.GetFirstFlight()
-	airlineName := flight.Airline.FullName
-	departure := flight.Origin.FriendlyLocation
-	arrival := flight.Destination.FriendlyLocation
+	found := i18n.T(locale, "track.found", request.FlightNumber, legs[0].Origin, i18n.FormatTime(locale, departure), i18n.FormatDate(locale, departure))
+	TrackFlight(locale, request, departure, slashCommand.ChannelID, slashCommand.UserID, found, config)
+	return nil
+}
 
-	blocks := []slack.Block{
-		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, ":beverage_box: So you're taking a flight from *"+departure+"* to *"+arrival+"* with *"+airlineName+"*?", false, false),
-			nil,
-			nil,
-		),
+// TrackFlight subscribes the channel (or the user) to a flight and confirms it, the notice being shown first
+func TrackFlight(locale string, request shared.TrackRequest, departure time.Time, channelID string, userID string, notice string, config shared.Config) {
+	target, fallback := alertTarget(locale, request, channelID, userID, config)
+	request.DM = shared.IsUserID(target)
+	notice = strings.TrimSpace(notice + "\n" + fallback)
+	if request.TravelerID == userID {
+		request.TravelerID = ""
+	}
+
+	// subscribe to the flight, sharing the poll if someone already tracks it
+	sub, err := shared.SubscribeToFlight(request.FlightNumber, departure.Unix(), shared.Subscription{
+		SlackChannel: target,
+		SlackUserID:  userID,
+		TravelerID:   request.TravelerID,
+	}, config)
+	if err != nil {
+		log.Printf("Error registering tracked flight: %v\n", err)
+		if dm, dmErr := shared.DeliveryChannel(userID, config); dmErr == nil {
+			config.SlackClient.PostMessage(dm, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, err)...))
+		}
+		return
+	}
+
+	confirmation := i18n.T(locale, "track.confirmation", channelID)
+	if sub.TravelerID != "" {
+		confirmation = i18n.T(locale, "track.confirmation_traveler", sub.TravelerID, sub.Destination())
+	} else if sub.IsDM() {
+		confirmation = i18n.T(locale, "track.confirmation_dm")
+	}
+	if notice != "" {
+		confirmation = notice + "\n" + confirmation
+	}
+
+	if sub.IsDM() {
+		// the bot might not be in the channel, so confirm where the alerts will arrive
+		dm, err := shared.DeliveryChannel(sub.SlackChannel, config)
+		if err == nil {
+			_, _, err = config.SlackClient.PostMessage(dm, slack.MsgOptionBlocks(
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject("mrkdwn", confirmation+" "+i18n.T(locale, "track.confirmation_flight", request.FlightNumber), false, false),
+					nil,
+					nil,
+				),
+			))
+		}
+		if err != nil {
+			log.Printf("Error sending tracking confirmation by dm: %v\n", err)
+		}
+		return
+	}
+
+	// send a message to the channel confirming the tracking
+	config.SlackClient.PostEphemeral(channelID, userID, slack.MsgOptionBlocks(
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, "Sounds great! We just need a bit more info...", false, false),
+			slack.NewTextBlockObject("mrkdwn", confirmation, false, false),
 			nil,
 			nil,
 		),
-		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, "_Departure date_:", false, false),
+	))
+}
+
+// alertTarget returns where the alerts of a tracked flight go: the channel, or the dms of the user who tracked it.
+// Alerts in the channel need the bot in it, otherwise they are sent by dm with a notice explaining why.
+func alertTarget(locale string, request shared.TrackRequest, channelID string, userID string, config shared.Config) (string, string) {
+	if request.DM {
+		return userID, ""
+	}
+	info, err := config.SlackClient.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil || !info.IsMember && !info.IsOpen {
+		return userID, i18n.T(locale, "track.dm_fallback")
+	}
+	return channelID, ""
+}
+
+// LoadTrackModal fetches the schedule of the requested flight and returns the modal offering its legs
+func LoadTrackModal(locale string, channelID string, request shared.TrackRequest) slack.ModalViewRequest {
+	flightsInfo, err := flights.GetFlightInfo(request.FlightNumber)
+	if err != nil {
+		log.Printf("Error fetching flight info for %s: %v\n", request.FlightNumber, err)
+		return TrackModal(locale, channelID, request, "", nil, i18n.T(locale, "track.fetch_error", request.FlightNumber))
+	}
+
+	flight := flightsInfo.GetFirstFlight()
+	legs := flightsInfo.Legs()
+	if date, err := time.Parse(time.DateOnly, request.Date); err == nil {
+		legs = flightsInfo.LegsOn(date)
+	}
+	if flight == nil || flight.Airline.FullName == "" || len(legs) == 0 {
+		return TrackModal(locale, channelID, request, "", nil, i18n.T(locale, "track.not_found")+"\n"+i18n.T(locale, "track.not_found_hint"))
+	}
+	if request.Origin != "" {
+		legs = legsFrom(legs, request.Origin)
+		if len(legs) == 0 {
+			return TrackModal(locale, channelID, request, "", nil, i18n.T(locale, "track.origin_not_found", request.FlightNumber, request.Origin))
+		}
+	}
+
+	intro := i18n.T(locale, "track.intro", flight.Origin.FriendlyLocation, flight.Destination.FriendlyLocation, flight.Airline.FullName)
+	if request.TravelerID != "" {
+		intro = i18n.T(locale, "track.intro_traveler", request.TravelerID, flight.Origin.FriendlyLocation, flight.Destination.FriendlyLocation, flight.Airline.FullName)
+	}
+	return TrackModal(locale, channelID, request, intro, legs, "")
+}
+
+// TrackModal builds the tracking modal. The legs are those of request.FlightNumber on request.Date,
+// the private metadata ("channel_id|flight_number|leg") telling which flight number they were loaded for,
+// and holding the leg when there is only one to choose from.
+func TrackModal(locale string, channelID string, request shared.TrackRequest, intro string, legs []flights.Leg, status string) slack.ModalViewRequest {
+	var blocks []slack.Block
+	if intro != "" {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, intro, false, false),
 			nil,
-			slack.NewAccessory(
-				slack.NewDatePickerBlockElement(
-					"trackflightformsubmit-departuredate",
-				),
-			),
-		),
-		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, "_Departure time_: (choose the nearest time to your actual departure time, but before it)", false, false),
 			nil,
-			slack.NewAccessory(
-				slack.NewTimePickerBlockElement(
-					"trackflightformsubmit-departuretime",
-				),
-			),
-		),
-		slack.NewActionBlock(
-			"track_flight_submit_button",
+		))
+	}
+
+	// for those who don't know the code of their airline, searched in the airlines database
+	minQueryLength := 2
+	airline := slack.NewOptionsSelectBlockElement(slack.OptTypeExternal, slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.airline_placeholder"), false, false), "trackflight-airline")
+	airline.MinQueryLength = &minQueryLength
+	airlineInput := slack.NewInputBlock(
+		"trackflight-airline",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.airline"), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.airline_hint"), false, false),
+		airline,
+	)
+	airlineInput.Optional = true
+	airlineInput.DispatchAction = true
+	blocks = append(blocks, airlineInput)
+
+	// pressing enter in the flight number loads its schedule
+	number := slack.NewPlainTextInputBlockElement(nil, "trackflight-number")
+	number.InitialValue = request.FlightNumber
+	number.DispatchActionConfig = &slack.DispatchActionConfig{TriggerActionsOn: []string{"on_enter_pressed"}}
+	numberInput := slack.NewInputBlock(
+		"trackflight-number",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.number"), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.number_hint"), false, false),
+		number,
+	)
+	numberInput.DispatchAction = true
+	blocks = append(blocks, numberInput)
+
+	if status != "" {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, status, false, false),
+		))
+	}
+
+	// picking a date reloads the legs of that day
+	date := slack.NewDatePickerBlockElement("trackflight-date")
+	date.InitialDate = request.Date
+	dateInput := slack.NewInputBlock(
+		"trackflight-date",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.date"), false, false),
+		nil,
+		date,
+	)
+	dateInput.DispatchAction = true
+	blocks = append(blocks, dateInput)
+
+	// for flights with several legs a day, the departure airport keeps only the legs leaving from it
+	origin := slack.NewOptionsSelectBlockElement(slack.OptTypeExternal, slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.origin_placeholder"), false, false), "trackflight-origin")
+	origin.MinQueryLength = &minQueryLength
+	if request.Origin != "" {
+		airport, err := flights.GetAirport(request.Origin)
+		if err != nil {
+			airport = &flights.Airport{IATA: request.Origin, Name: request.Origin}
+		}
+		origin.InitialOption = AirportOption(locale, *airport)
+	}
+	originInput := slack.NewInputBlock(
+		"trackflight-origin",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.origin"), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.origin_hint"), false, false),
+		origin,
+	)
+	originInput.Optional = true
+	originInput.DispatchAction = true
+	blocks = append(blocks, originInput)
+
+	metadata := channelID + "|" + request.FlightNumber
+	if len(legs) == 1 {
+		// nothing to choose, the departure is the one of the schedule
+		metadata += "|" + legs[0].Value()
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "track.departure", legs[0].Origin, legs[0].Destination, i18n.FormatTime(locale, legs[0].Departure)), false, false),
+		))
+	} else if len(legs) > 1 {
+		options := make([]*slack.OptionBlockObject, 0, len(legs))
+		for _, leg := range legs {
+			text := i18n.T(locale, "track.leg_option", leg.Origin, leg.Destination, i18n.FormatTime(locale, leg.Departure))
+			options = append(options, slack.NewOptionBlockObject(leg.Value(), slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil))
+		}
+		legSelect := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.leg_placeholder"), false, false), "trackflight-leg", options...)
+		blocks = append(blocks, slack.NewInputBlock(
+			"trackflight-leg",
+			slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.leg"), false, false),
+			slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.leg_hint"), false, false),
+			legSelect,
+		))
+	}
+
+	channel := slack.NewOptionsSelectBlockElement(slack.OptTypeConversations, nil, "trackflight-channel")
+	channel.InitialConversation = channelID
+	channel.Filter = &slack.SelectBlockElementFilter{Include: []string{"public", "private"}}
+	blocks = append(blocks, slack.NewInputBlock(
+		"trackflight-channel",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.channel"), false, false),
+		nil,
+		channel,
+	))
+
+	traveler := slack.NewOptionsSelectBlockElement(slack.OptTypeUser, nil, "trackflight-traveler")
+	traveler.InitialUser = request.TravelerID
+	travelerInput := slack.NewInputBlock(
+		"trackflight-traveler",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.traveler"), false, false),
+		nil,
+		traveler,
+	)
+	travelerInput.Optional = true
+	blocks = append(blocks, travelerInput)
+
+	dmOption := slack.NewOptionBlockObject("dm", slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.option_dm"), false, false), nil)
+	options := slack.NewCheckboxGroupsBlockElement("trackflight-options", dmOption)
+	if request.DM {
+		options.InitialOptions = []*slack.OptionBlockObject{dmOption}
+	}
+	optionsInput := slack.NewInputBlock(
+		"trackflight-options",
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.options"), false, false),
+		nil,
+		options,
+	)
+	optionsInput.Optional = true
+	blocks = append(blocks, optionsInput)
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      "trackflight-submit",
+		PrivateMetadata: metadata,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.title"), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.submit"), false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "modal.cancel"), false, false),
+		Blocks:          slack.Blocks{BlockSet: blocks},
+	}
+}
+
+// AirportOption is the option of an airport in the selects searching them, its value being the iata code
+func AirportOption(locale string, airport flights.Airport) *slack.OptionBlockObject {
+	text := i18n.T(locale, "track.origin_option", airport.Name, airport.IATA)
+	return slack.NewOptionBlockObject(airport.IATA, slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil)
+}
+
+// legsFrom keeps the legs leaving from an airport
+func legsFrom(legs []flights.Leg, origin string) []flights.Leg {
+	var kept []flights.Leg
+	for _, leg := range legs {
+		if strings.EqualFold(leg.Origin, origin) {
+			kept = append(kept, leg)
+		}
+	}
+	return kept
+}
+
+// trackButton opens the tracking modal, for answers that can't open it directly
+func trackButton(locale string, request shared.TrackRequest, config shared.Config) []slack.Block {
+	value, err := shared.EncodeAction(shared.ActionValue{Track: &request}, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err)
+	}
+
+	text := i18n.T(locale, "track.open")
+	if request.FlightNumber != "" {
+		text = i18n.T(locale, "track.open_flight", request.FlightNumber)
+	}
+	return []slack.Block{
+		slack.NewActionBlock("",
 			slack.NewButtonBlockElement(
-				"trackflightformsubmit-button",
-				flightNumber,
-				slack.NewTextBlockObject(slack.PlainTextType, "Track Flight", false, false),
+				"trackopen-button",
+				value,
+				slack.NewTextBlockObject(slack.PlainTextType, text, false, false),
 			).WithStyle(slack.StylePrimary),
 		),
 	}
+}
 
-	return blocks, false, nil
+// userNow returns the current time in the timezone of a user, for relative dates like "tomorrow"
+func userNow(userID string, config shared.Config) time.Time {
+	user, err := config.SlackClient.GetUserInfo(userID)
+	if err != nil {
+		return time.Now()
+	}
+	loc, err := time.LoadLocation(user.TZ)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Now().In(loc)
 }
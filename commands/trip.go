@@ -0,0 +1,379 @@
+package commands
+
+import (
+	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+var TripCommand = shared.Command{
+	Name:        "track-trip",
+	Description: "Track a trip with connections",
+	Usage:       "/track-trip [flight_number] [today|tomorrow|YYYY-MM-DD] [flight_number] [date (optional)]... [for @someone (optional)] [dm (optional)]",
+	Execute:     TrackTrip,
+}
+
+// plannedFlight is a flight of the command arguments, dated if a date followed it
+type plannedFlight struct {
+	number string
+	date   time.Time
+}
+
+// plannedLeg is a flight found in the schedule, with its departure on the day of the trip
+type plannedLeg struct {
+	number    string
+	leg       flights.Leg
+	departure time.Time
+}
+
+// TrackTrip tracks the flights of a journey together, to be warned when a connection gets tight.
+// Without arguments, it shows the trips of the user.
+func TrackTrip(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
+	usage := []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "trip.usage", i18n.T(locale, "command.track-trip.usage")), false, false),
+			nil,
+			nil,
+		),
+	}
+
+	args, err := shlex.Split(slashCommand.Text)
+	if err != nil {
+		return usage, false, nil
+	}
+	if len(args) == 0 {
+		return Trips(locale, slashCommand.UserID, config), false, nil
+	}
+
+	// flights in order, each optionally followed by its date, then "for @someone" and "dm"
+	var request shared.TrackRequest
+	var planned []plannedFlight
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		switch {
+		case arg == "dm":
+			request.DM = true
+		case arg == "for":
+			if i+1 < len(args) {
+				if matches := userMentionPattern.FindStringSubmatch(args[i+1]); matches != nil {
+					request.TravelerID = matches[1]
+					i++
+					continue
+				}
+			}
+			return []slack.Block{
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "track.who"), false, false),
+					nil,
+					nil,
+				),
+			}, false, nil
+		case flights.FlightNumPattern.MatchString(strings.ToUpper(arg)):
+			planned = append(planned, plannedFlight{number: strings.ToUpper(arg)})
+		default:
+			date, ok := parseDay(arg, slashCommand.UserID, config)
+			if !ok || len(planned) == 0 {
+				return usage, false, nil
+			}
+			planned[len(planned)-1].date = date
+		}
+	}
+	if len(planned) < 2 {
+		return usage, false, nil
+	}
+
+	legs, blocks := planTrip(locale, planned, slashCommand.UserID, config)
+	if blocks != nil {
+		return blocks, false, nil
+	}
+
+	trip, notice, err := createTrip(locale, request, legs, slashCommand.ChannelID, slashCommand.UserID, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err), false, nil
+	}
+
+	tripLegs, err := shared.GetTripLegs(shared.TripLegFilter{TripID: trip.ID}, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err), false, nil
+	}
+	text := i18n.T(locale, "trip.confirmation")
+	if notice != "" {
+		text = notice + "\n" + text
+	}
+	return append([]slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, text, false, false),
+			nil,
+			nil,
+		),
+	}, TripBlocks(locale, trip, tripLegs, config)...), false, nil
+}
+
+// planTrip finds the legs of the trip in the schedules, the first flight leaving today unless dated.
+// When a flight can't be found, it returns the blocks explaining why.
+func planTrip(locale string, planned []plannedFlight, userID string, config shared.Config) ([]plannedLeg, []slack.Block) {
+	message := func(text string) []slack.Block {
+		return []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, text, false, false),
+				nil,
+				nil,
+			),
+		}
+	}
+
+	var legs []plannedLeg
+	for i, p := range planned {
+		flightsInfo, err := flights.GetFlightInfo(p.number)
+		if err != nil {
+			log.Printf("Error fetching flight info for %s: %v\n", p.number, err)
+			return nil, message(i18n.T(locale, "track.fetch_error", p.number))
+		}
+
+		var prev *plannedLeg
+		if i > 0 {
+			prev = &legs[i-1]
+		} else if p.date.IsZero() {
+			p.date = userNow(userID, config)
+		}
+		leg, date, problem := nextLeg(p, prev, flightsInfo.LegsOn)
+		if problem != "" {
+			return nil, message(i18n.T(locale, problem, p.number, i18n.FormatDate(locale, date)))
+		}
+		if time.Since(leg.departure) > 24*time.Hour {
+			return nil, message(i18n.T(locale, "track.date_past"))
+		}
+		legs = append(legs, leg)
+	}
+	return legs, nil
+}
+
+// nextLeg picks the leg of a flight of the trip, legsOn giving its schedule on a day. The first flight needs a date,
+// the next ones without a date depart the day the previous one arrives, or the day after, and among several legs
+// that day the one leaving from where the previous flight lands is taken.
+// Without a single leg, it returns the key of the message explaining why, and the day that was looked at.
+func nextLeg(p plannedFlight, prev *plannedLeg, legsOn func(date time.Time) []flights.Leg) (plannedLeg, time.Time, string) {
+	date := p.date
+	var arrival time.Time
+	if prev != nil {
+		arrival = prev.departure.Add(prev.leg.Duration)
+		if date.IsZero() {
+			date = arrival
+		}
+	}
+
+	candidates := legsOn(date)
+	if prev != nil {
+		var connecting []flights.Leg
+		for _, leg := range candidates {
+			if leg.Origin == prev.leg.Destination {
+				connecting = append(connecting, leg)
+			}
+		}
+		if len(connecting) > 0 {
+			candidates = connecting
+		}
+	}
+	switch {
+	case len(candidates) == 0:
+		return plannedLeg{}, date, "trip.not_found"
+	case len(candidates) > 1:
+		return plannedLeg{}, date, "trip.ambiguous"
+	}
+
+	departure := candidates[0].On(date)
+	// an undated connection leaving earlier in the day than the arrival is the next day's
+	if p.date.IsZero() && prev != nil && departure.Before(arrival) {
+		departure = departure.AddDate(0, 0, 1)
+	}
+	return plannedLeg{number: p.number, leg: candidates[0], departure: departure}, date, ""
+}
+
+// createTrip subscribes to the flights of a trip and groups them, returning the trip and a notice if the alerts go to dms
+func createTrip(locale string, request shared.TrackRequest, legs []plannedLeg, channelID string, userID string, config shared.Config) (shared.Trip, string, error) {
+	target, notice := alertTarget(locale, request, channelID, userID, config)
+	if request.TravelerID == userID {
+		request.TravelerID = ""
+	}
+
+	trip := shared.Trip{
+		ID:           uuid.New().String(),
+		SlackChannel: target,
+		SlackUserID:  userID,
+		TravelerID:   request.TravelerID,
+		CreatedAt:    time.Now().Unix(),
+	}
+	// the subscriptions the channel already had are kept if the trip can't be created
+	existing, err := shared.GetSubscriptions(shared.SubscriptionFilter{SlackChannel: target}, config)
+	if err != nil {
+		return trip, "", err
+	}
+	kept := map[string]bool{}
+	for _, sub := range existing {
+		kept[sub.ID] = true
+	}
+
+	if err := shared.CreateTrip(trip, config); err != nil {
+		return trip, "", err
+	}
+
+	var created []string
+	for _, l := range legs {
+		sub, err := shared.SubscribeToFlight(l.number, l.departure.Unix(), shared.Subscription{
+			SlackChannel: target,
+			SlackUserID:  userID,
+			TravelerID:   request.TravelerID,
+		}, config)
+		if err != nil {
+			undoTrip(trip.ID, created, config)
+			return trip, "", err
+		}
+		if !kept[sub.ID] {
+			created = append(created, sub.ID)
+		}
+
+		leg := shared.TripLeg{
+			TripID:         trip.ID,
+			SubscriptionID: sub.ID,
+			Origin:         l.leg.Origin,
+			Destination:    l.leg.Destination,
+		}
+		if l.leg.Duration > 0 {
+			leg.Arrival = l.departure.Add(l.leg.Duration).Unix()
+		}
+		if err := shared.AddTripLeg(leg, config); err != nil {
+			undoTrip(trip.ID, created, config)
+			return trip, "", err
+		}
+	}
+	return trip, notice, nil
+}
+
+// undoTrip removes a trip that couldn't be created entirely, with the subscriptions created for it
+func undoTrip(tripID string, created []string, config shared.Config) {
+	if err := shared.DeleteTrip(tripID, config); err != nil {
+		log.Printf("Error removing trip %s: %v\n", tripID, err)
+	}
+	for _, id := range created {
+		if err := shared.Unsubscribe(id, config); err != nil {
+			log.Printf("Error removing subscription %s: %v\n", id, err)
+		}
+	}
+}
+
+// Trips shows the trips tracked by a user
+func Trips(locale string, userID string, config shared.Config) []slack.Block {
+	trips, err := shared.GetTrips(shared.TripFilter{SlackUserID: userID}, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err)
+	}
+
+	var blocks []slack.Block
+	for _, trip := range trips {
+		legs, err := shared.GetTripLegs(shared.TripLegFilter{TripID: trip.ID}, config)
+		if err != nil {
+			return shared.NewErrorBlocks(locale, err)
+		}
+		if len(blocks) > 0 {
+			blocks = append(blocks, slack.NewDividerBlock())
+		}
+		blocks = append(blocks, TripBlocks(locale, trip, legs, config)...)
+	}
+
+	if len(blocks) == 0 {
+		return []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "trip.empty"), false, false),
+				nil,
+				nil,
+			),
+		}
+	}
+	return blocks
+}
+
+// TripBlocks describes a trip: its route, its legs and the time left at each connection
+func TripBlocks(locale string, trip shared.Trip, legs []shared.TripLeg, config shared.Config) []slack.Block {
+	if len(legs) == 0 {
+		return nil
+	}
+
+	route := []string{legs[0].Origin}
+	for _, leg := range legs {
+		route = append(route, leg.Destination)
+	}
+	destination := shared.Subscription{SlackChannel: trip.SlackChannel}.Destination()
+	header := i18n.T(locale, "trip.header", strings.Join(route, " → "), destination)
+	if trip.TravelerID != "" {
+		header = i18n.T(locale, "trip.header_traveler", strings.Join(route, " → "), trip.TravelerID, destination)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, header, false, false),
+			nil,
+			nil,
+		),
+	}
+
+	connections := shared.Connections(legs, func(flightID string) *shared.FlightState {
+		state, _ := shared.GetFlightState(flightID, config)
+		return state
+	})
+	for i, leg := range legs {
+		departure := time.Unix(leg.Subscription.Departure, 0)
+		when := shared.SlackDate(departure, "{date_short_pretty} {time}", departure.UTC().Format(time.RFC822))
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "trip.leg", leg.Subscription.FlightNumber, leg.Origin, leg.Destination, when), false, false),
+			nil,
+			nil,
+		))
+		if i < len(connections) {
+			blocks = append(blocks, slack.NewContextBlock("",
+				slack.NewTextBlockObject(slack.MarkdownType, connectionText(locale, connections[i]), false, false),
+			))
+		}
+	}
+	return blocks
+}
+
+func connectionText(locale string, c shared.Connection) string {
+	var text string
+	switch c.Risk() {
+	case shared.ConnectionRiskUnknown:
+		text = i18n.T(locale, "trip.connection_unknown", c.Airport)
+	case shared.ConnectionRiskMissed:
+		text = i18n.T(locale, "trip.connection_missed", c.Airport, i18n.FormatDuration(locale, -c.Buffer()))
+	case shared.ConnectionRiskHigh:
+		text = i18n.T(locale, "trip.connection_at_risk", i18n.FormatDuration(locale, c.Buffer()), c.Airport, i18n.FormatDuration(locale, c.MinimumTime))
+	case shared.ConnectionRiskTight:
+		text = i18n.T(locale, "trip.connection_tight", i18n.FormatDuration(locale, c.Buffer()), c.Airport)
+	default:
+		text = i18n.T(locale, "trip.connection", i18n.FormatDuration(locale, c.Buffer()), c.Airport)
+	}
+
+	if c.ArrivalTerminal != "" && c.DepartureTerminal != "" {
+		text += i18n.T(locale, "trip.terminals", c.ArrivalTerminal, c.DepartureTerminal)
+	}
+	return text
+}
+
+// parseDay parses "today", "tomorrow" (for the user) or a YYYY-MM-DD date
+func parseDay(arg string, userID string, config shared.Config) (time.Time, bool) {
+	switch arg {
+	case "today":
+		return userNow(userID, config), true
+	case "tomorrow":
+		return userNow(userID, config).AddDate(0, 0, 1), true
+	}
+	date, err := time.Parse(time.DateOnly, arg)
+	return date, err == nil
+}
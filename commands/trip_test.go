@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"flight-tracker-slack/flights"
+	"testing"
+	"time"
+)
+
+func TestNextLeg(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	newYork, _ := time.LoadLocation("America/New_York")
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, paris)
+
+	// AF006 CDG-JFK lands at 13:00 in New York
+	first := plannedLeg{
+		number:    "AF006",
+		leg:       flights.Leg{Origin: "CDG", Destination: "JFK", Departure: time.Date(2025, 6, 1, 10, 30, 0, 0, paris), Duration: 8*time.Hour + 30*time.Minute},
+		departure: time.Date(2025, 6, 1, 10, 30, 0, 0, paris),
+	}
+	leg := func(origin string, destination string, hour int) flights.Leg {
+		return flights.Leg{Origin: origin, Destination: destination, Departure: time.Date(2025, 6, 1, hour, 0, 0, 0, newYork)}
+	}
+	schedule := func(legs ...flights.Leg) func(time.Time) []flights.Leg {
+		return func(time.Time) []flights.Leg { return legs }
+	}
+
+	tests := []struct {
+		name          string
+		planned       plannedFlight
+		prev          *plannedLeg
+		legsOn        func(time.Time) []flights.Leg
+		wantDeparture time.Time
+		wantProblem   string
+	}{
+		{
+			name:          "first flight on its date",
+			planned:       plannedFlight{number: "AF006", date: day},
+			legsOn:        schedule(first.leg),
+			wantDeparture: first.departure,
+		},
+		{
+			name:          "connection later that day",
+			planned:       plannedFlight{number: "DL100"},
+			prev:          &first,
+			legsOn:        schedule(leg("JFK", "LAX", 16)),
+			wantDeparture: time.Date(2025, 6, 1, 16, 0, 0, 0, newYork),
+		},
+		{
+			name:          "connection leaving before the arrival is the next day's",
+			planned:       plannedFlight{number: "DL100"},
+			prev:          &first,
+			legsOn:        schedule(leg("JFK", "LAX", 9)),
+			wantDeparture: time.Date(2025, 6, 2, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:          "the leg leaving from the arrival airport is taken",
+			planned:       plannedFlight{number: "DL100"},
+			prev:          &first,
+			legsOn:        schedule(leg("BOS", "JFK", 12), leg("JFK", "LAX", 16)),
+			wantDeparture: time.Date(2025, 6, 1, 16, 0, 0, 0, newYork),
+		},
+		{
+			name:        "several legs from elsewhere",
+			planned:     plannedFlight{number: "DL100"},
+			prev:        &first,
+			legsOn:      schedule(leg("BOS", "ATL", 12), leg("ATL", "LAX", 16)),
+			wantProblem: "trip.ambiguous",
+		},
+		{
+			name:        "not found",
+			planned:     plannedFlight{number: "DL100"},
+			prev:        &first,
+			legsOn:      schedule(),
+			wantProblem: "trip.not_found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, problem := nextLeg(tt.planned, tt.prev, tt.legsOn)
+			if problem != tt.wantProblem {
+				t.Fatalf("got problem %q, want %q", problem, tt.wantProblem)
+			}
+			if problem == "" && !got.departure.Equal(tt.wantDeparture) {
+				t.Errorf("got departure %s, want %s", got.departure, tt.wantDeparture)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package commands
 
 import (
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
+	"log"
+	"strings"
 
 	"github.com/google/shlex"
 	"github.com/slack-go/slack"
@@ -10,23 +13,27 @@ import (
 var UntrackCommand = shared.Command{
 	Name:        "untrack-flight",
 	Description: "Untrack a flight",
-	Usage:       "/untrack-flight [flight_number] [channel (optional)]",
+	Usage:       "/untrack-flight [flight_number (optional)] [channel (optional)]",
 	Execute:     Untrack,
 }
 
 func Untrack(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
 	args, err := shlex.Split(slashCommand.Text)
-	if err != nil || len(args) < 1 {
+	if err == nil && len(args) == 0 {
+		return UntrackSelect(locale), false, nil
+	}
+	if err != nil {
 		return []slack.Block{
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "This didn't work :pensive: \n _Usage: `/untrack-flight [flight_number] [channel (optional)]`_ \n You can also use `/list-flights` to see all your tracked flights.", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "untrack.usage", i18n.T(locale, "command.untrack-flight.usage")), false, false),
 				nil,
 				nil,
 			),
 		}, false, nil
 	}
 
-	flightNumber := args[0]
+	flightNumber := strings.ToUpper(args[0])
 	var channelID string
 	if len(args) >= 2 {
 		channelID = args[1]
@@ -34,31 +41,31 @@ func Untrack(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Blo
 		channelID = slashCommand.ChannelID
 	}
 
-	var filter = shared.FlightFilter{
+	var filter = shared.SubscriptionFilter{
 		SlackUserID:  slashCommand.UserID,
 		FlightNumber: flightNumber,
 		SlackChannel: channelID,
 	}
-	flights, err := shared.GetFlights(filter, config)
+	flights, err := shared.GetSubscriptions(filter, config)
 
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return shared.NewErrorBlocks(locale, err), false, nil
 	}
 
 	if len(flights) == 0 {
 		// no flight found to untrack, list all flights for the user corresponding to the flight number$
-		var filter = shared.FlightFilter{
+		var filter = shared.SubscriptionFilter{
 			SlackUserID:  slashCommand.UserID,
 			FlightNumber: flightNumber,
 		}
-		flights, err := shared.GetFlights(filter, config)
+		flights, err := shared.GetSubscriptions(filter, config)
 		if err != nil {
-			return shared.NewErrorBlocks(err), false, nil
+			return shared.NewErrorBlocks(locale, err), false, nil
 		}
 		if len(flights) == 0 {
 			return []slack.Block{
 				slack.NewSectionBlock(
-					slack.NewTextBlockObject(slack.MarkdownType, "I couldn't find any tracked flight with that number :pensive:", false, false),
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "untrack.not_found"), false, false),
 					nil,
 					nil,
 				),
@@ -66,37 +73,64 @@ func Untrack(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Blo
 		} else {
 			var blocks []slack.Block
 			blocks = append(blocks, slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, "I couldn't find a tracked flight with that number in this channel, but here are the flights with that number that you're tracking in other channels:", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "untrack.other_channels"), false, false),
 				nil,
 				nil,
 			))
 			for _, flight := range flights {
 				blocks = append(blocks, slack.NewSectionBlock(
-					slack.NewTextBlockObject(slack.MarkdownType, "• *"+flight.FlightNumber+"* in channel <#"+flight.SlackChannel+">", false, false),
+					slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "untrack.flight", flight.FlightNumber, travelerText(locale, flight), flight.Destination()), false, false),
 					nil,
-					slack.NewAccessory(
-						slack.NewButtonBlockElement(
-							"untrack-"+flight.FlightNumber+"-"+flight.SlackChannel,
-							"",
-							slack.NewTextBlockObject(slack.PlainTextType, "Untrack", false, false),
-						).WithStyle(slack.StyleDanger),
-					),
+					slack.NewAccessory(UntrackButton(locale, flight, config)),
 				))
 			}
 			return blocks, false, nil
 		}
 	}
 
-	err = shared.UntrackFlight(flights[0].ID, config)
+	err = shared.Unsubscribe(flights[0].ID, config)
 	if err != nil {
-		return shared.NewErrorBlocks(err), false, nil
+		return shared.NewErrorBlocks(locale, err), false, nil
 	}
 
 	return []slack.Block{
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, "Successfully untracked flight *"+flightNumber+"* in channel <#"+channelID+">.", false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "untrack.success", flightNumber, channelID), false, false),
 			nil,
 			nil,
 		),
 	}, false, nil
 }
+
+// UntrackSelect offers to untrack one of the flights of the user, searched as they type
+func UntrackSelect(locale string) []slack.Block {
+	minQueryLength := 0
+	flightSelect := slack.NewOptionsSelectBlockElement(
+		slack.OptTypeExternal,
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "untrack.select_placeholder"), false, false),
+		"untrackselect",
+	)
+	flightSelect.MinQueryLength = &minQueryLength
+
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "untrack.select"), false, false),
+			nil,
+			slack.NewAccessory(flightSelect),
+		),
+	}
+}
+
+// UntrackButton untracks exactly one subscription, named in its signed value.
+// The action id only has to be unique within the message.
+func UntrackButton(locale string, sub shared.Subscription, config shared.Config) *slack.ButtonBlockElement {
+	value, err := shared.EncodeAction(shared.ActionValue{FlightID: sub.FlightID, SubscriptionID: sub.ID}, config)
+	if err != nil {
+		log.Printf("Error encoding untrack action of %s: %v", sub.ID, err)
+	}
+	return slack.NewButtonBlockElement(
+		"untrack-"+sub.ID,
+		value,
+		slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "list.untrack"), false, false),
+	).WithStyle(slack.StyleDanger)
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"errors"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"flight-tracker-slack/webhooks"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+var WebhooksCommand = shared.Command{
+	Name:        "flight-webhooks",
+	Description: "Send the flight events of this channel to your own tools",
+	Usage:       "/flight-webhooks [add url|remove id|test id|log id|list] [workspace (optional)]",
+	Execute:     Webhooks,
+}
+
+// number of deliveries shown by "log"
+const webhookLogSize = 10
+
+func Webhooks(slashCommand slack.SlashCommand, config shared.Config) ([]slack.Block, bool, func() error) {
+	locale := shared.ResolveLocale(slashCommand.ChannelID, slashCommand.UserID, config)
+
+	args, err := shlex.Split(slashCommand.Text)
+	if err != nil || len(args) < 1 {
+		args = []string{"list"}
+	}
+	action := strings.ToLower(args[0])
+	args = args[1:]
+
+	// channel webhooks by default, workspace ones with "workspace"
+	scopeID := slashCommand.ChannelID
+	scopeText := "<#" + slashCommand.ChannelID + ">"
+	if len(args) > 0 && strings.ToLower(args[len(args)-1]) == "workspace" {
+		scopeID = shared.WorkspaceScope
+		scopeText = i18n.T(locale, "templates.scope_workspace")
+		args = args[:len(args)-1]
+
+		admin, err := isWorkspaceAdmin(slashCommand.UserID, config)
+		if err != nil {
+			return shared.NewErrorBlocks(locale, err), false, nil
+		}
+		if !admin {
+			return textBlocks(i18n.T(locale, "webhooks.admin_only")), false, nil
+		}
+	}
+
+	switch action {
+	case "list":
+		return listWebhooks(locale, scopeID, scopeText, config), false, nil
+	case "add":
+		if len(args) < 1 {
+			break
+		}
+		return addWebhook(locale, args[0], scopeID, scopeText, slashCommand.UserID, config), false, nil
+	case "remove", "test", "log":
+		if len(args) < 1 {
+			break
+		}
+		hooks, err := shared.GetWebhooks(shared.WebhookFilter{ID: args[0], ScopeID: scopeID}, config)
+		if err != nil {
+			return shared.NewErrorBlocks(locale, err), false, nil
+		}
+		if len(hooks) == 0 {
+			return textBlocks(i18n.T(locale, "webhooks.not_found", args[0], scopeText)), false, nil
+		}
+		hook := hooks[0]
+
+		switch action {
+		case "remove":
+			if _, err := shared.DeleteWebhook(hook.ID, scopeID, config); err != nil {
+				return shared.NewErrorBlocks(locale, err), false, nil
+			}
+			return textBlocks(i18n.T(locale, "webhooks.removed", hook.URL)), false, nil
+		case "test":
+			delivery := webhooks.DeliverOnce(hook, webhooks.NewEvent(webhooks.EventTest, shared.Flight{}, nil, nil), config)
+			if delivery.Error != "" {
+				return textBlocks(i18n.T(locale, "webhooks.test_failed", hook.URL, delivery.Error)), false, nil
+			}
+			return textBlocks(i18n.T(locale, "webhooks.test_ok", hook.URL, delivery.StatusCode, delivery.Duration)), false, nil
+		case "log":
+			return webhookLog(locale, hook, config), false, nil
+		}
+	}
+
+	return textBlocks(i18n.T(locale, "error.usage", i18n.T(locale, "command.flight-webhooks.usage"))), false, nil
+}
+
+func addWebhook(locale string, rawURL string, scopeID string, scopeText string, userID string, config shared.Config) []slack.Block {
+	// slack sends links as <https://example.com> or <https://example.com|example.com>
+	rawURL = strings.TrimSuffix(strings.TrimPrefix(rawURL, "<"), ">")
+	rawURL, _, _ = strings.Cut(rawURL, "|")
+
+	u, err := webhooks.ValidateURL(rawURL, config)
+	if errors.Is(err, webhooks.ErrPrivateAddress) {
+		return textBlocks(i18n.T(locale, "webhooks.private_url", rawURL))
+	}
+	if err != nil {
+		return textBlocks(i18n.T(locale, "webhooks.invalid_url"))
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err)
+	}
+	hook := shared.Webhook{
+		ID:        uuid.New().String()[:8],
+		ScopeID:   scopeID,
+		URL:       u.String(),
+		Secret:    secret,
+		CreatedBy: userID,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := shared.CreateWebhook(hook, config); err != nil {
+		return shared.NewErrorBlocks(locale, err)
+	}
+
+	return textBlocks(i18n.T(locale, "webhooks.added", hook.URL, scopeText, hook.ID, hook.Secret))
+}
+
+func listWebhooks(locale string, scopeID string, scopeText string, config shared.Config) []slack.Block {
+	hooks, err := shared.GetWebhooks(shared.WebhookFilter{ScopeID: scopeID}, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err)
+	}
+	if len(hooks) == 0 {
+		return textBlocks(i18n.T(locale, "webhooks.empty", scopeText))
+	}
+
+	var text strings.Builder
+	text.WriteString(i18n.T(locale, "webhooks.list", scopeText) + "\n")
+	for _, hook := range hooks {
+		status := i18n.T(locale, "webhooks.never_delivered")
+		deliveries, err := shared.GetWebhookDeliveries(hook.ID, 1, config)
+		if err == nil && len(deliveries) > 0 {
+			status = deliveryText(locale, deliveries[0])
+		}
+		fmt.Fprintf(&text, "• `%s` %s — %s\n", hook.ID, hook.URL, status)
+	}
+	return textBlocks(text.String())
+}
+
+func webhookLog(locale string, hook shared.Webhook, config shared.Config) []slack.Block {
+	deliveries, err := shared.GetWebhookDeliveries(hook.ID, webhookLogSize, config)
+	if err != nil {
+		return shared.NewErrorBlocks(locale, err)
+	}
+	if len(deliveries) == 0 {
+		return textBlocks(i18n.T(locale, "webhooks.log_empty", hook.URL))
+	}
+
+	var text strings.Builder
+	text.WriteString(i18n.T(locale, "webhooks.log", hook.URL) + "\n")
+	for _, d := range deliveries {
+		fmt.Fprintf(&text, "• %s `%s` (#%d) — %s\n", shared.SlackDate(time.Unix(d.CreatedAt, 0), "{date_short} {time}", time.Unix(d.CreatedAt, 0).UTC().Format(time.RFC3339)), d.EventType, d.Attempt, deliveryText(locale, d))
+	}
+	return textBlocks(text.String())
+}
+
+func deliveryText(locale string, d shared.WebhookDelivery) string {
+	if d.Error != "" {
+		return i18n.T(locale, "webhooks.delivery_failed", d.Error)
+	}
+	return i18n.T(locale, "webhooks.delivery_ok", d.StatusCode, d.Duration)
+}
+
+func textBlocks(text string) []slack.Block {
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, text, false, false),
+			nil,
+			nil,
+		),
+	}
+}
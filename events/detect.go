@@ -0,0 +1,241 @@
+package events
+
+import (
+	"flight-tracker-slack/shared"
+	"fmt"
+	"time"
+)
+
+// SentFunc tells whether an alert was already sent to a subscriber
+type SentFunc func(subscriptionID string, alertID string) bool
+
+// DetectFlight compares two states of a flight and returns the flight-wide events that happened in between.
+// They don't depend on any preference or threshold: sinks filter what they need.
+func DetectFlight(f shared.Flight, prev *shared.FlightState, curr *shared.FlightState, now time.Time) []FlightEvent {
+	var events []FlightEvent
+	add := func(t Type) {
+		events = append(events, newEvent(t, f, prev, curr, now))
+	}
+
+	switch {
+	case prev.OriginGate == "" && curr.OriginGate != "":
+		add(GateAnnounced)
+	case prev.OriginGate != curr.OriginGate && curr.OriginGate != "":
+		add(GateChanged)
+	}
+	if prev.DepEstimated != 0 && curr.DepEstimated != 0 && prev.DepEstimated != curr.DepEstimated && curr.DepActual == 0 {
+		add(DepartureTimeChanged)
+	}
+	if prev.DepActual == 0 && curr.DepActual != 0 {
+		add(Departed)
+	}
+	if prev.TakeOffActual == 0 && curr.TakeOffActual != 0 {
+		add(TookOff)
+	}
+	if prev.DestGate != curr.DestGate && curr.DestGate != "" {
+		add(ArrivalGateChanged)
+	}
+	if terminalChanged(prev.OriginTerminal, curr.OriginTerminal) {
+		add(TerminalChanged)
+	}
+	if terminalChanged(prev.DestTerminal, curr.DestTerminal) {
+		add(ArrivalTerminalChanged)
+	}
+	if prev.ArrEstimated != 0 && curr.ArrEstimated != 0 && prev.ArrEstimated != curr.ArrEstimated && curr.ArrActual == 0 {
+		add(ArrivalTimeChanged)
+	}
+	if prev.LandingActual == 0 && curr.LandingActual != 0 {
+		add(Landed)
+	}
+	if prev.ArrActual == 0 && curr.ArrActual != 0 {
+		add(Arrived)
+	}
+	if prev.DestBaggage != curr.DestBaggage && curr.DestBaggage != "" {
+		add(BaggageClaimAssigned)
+	}
+	if inboundLateSteps(curr) > inboundLateSteps(prev) {
+		add(InboundAircraftLate)
+	}
+	return events
+}
+
+// DetectAlerts returns the alerts to send to a subscriber, using its own preferences,
+// and the subscription with its announced estimates updated.
+// It doesn't touch slack nor the database, sent telling which alerts went out already.
+func DetectAlerts(f shared.Flight, sub shared.Subscription, prefs shared.Preferences, prev *shared.FlightState, curr *shared.FlightState, now time.Time, sent SentFunc) ([]FlightEvent, shared.Subscription) {
+	var alerts []FlightEvent
+	add := func(t Type, alertID string, previous int64) {
+		if !prefs.Allows(t.Category()) || sent(sub.ID, alertID) {
+			return
+		}
+		event := newEvent(t, f, prev, curr, now)
+		subscription := sub
+		event.Subscription = &subscription
+		event.AlertID = alertID
+		event.Previous = previous
+		alerts = append(alerts, event)
+	}
+
+	// check if dep gate was announced
+	if curr.OriginGate != "" {
+		add(GateAnnounced, "departure_gate_announced", 0)
+	}
+
+	// check if the flight departed from gate
+	if curr.DepActual != 0 {
+		add(Departed, "flight_departed_from_gate", 0)
+	}
+
+	// check if the flight took off
+	if curr.TakeOffActual != 0 {
+		add(TookOff, "flight_takeoff", 0)
+	}
+
+	// check if flight landed
+	if curr.LandingActual != 0 {
+		add(Landed, "flight_landed", 0)
+	}
+
+	// check if the baggage belt was assigned, usually around the arrival
+	if curr.DestBaggage != "" {
+		add(BaggageClaimAssigned, fmt.Sprintf("baggage_claim_%s", curr.DestBaggage), 0)
+	}
+
+	// check if flight arrived at gate
+	// if it did, nothing else is worth sending
+	if curr.ArrActual != 0 {
+		add(Arrived, "flight_arrived_at_gate", 0)
+		return alerts, sub
+	}
+
+	// regular updates during the flight (1 every update interval, 2 hours by default)
+	if prefs.UpdateInterval > 0 && curr.DepActual != 0 {
+		window := int(now.Sub(time.Unix(curr.DepActual, 0)) / (time.Duration(prefs.UpdateInterval) * time.Second))
+		if window > 0 {
+			add(InFlightUpdate, fmt.Sprintf("in_flight_update_%d", window), 0)
+		}
+	}
+
+	// check if departure_time is updated (by at least the delay threshold, 15 minutes by default)
+	if depBaseline := lastAnnounced(sub.LastAnnouncedDepEstimated, prev.DepEstimated); prefs.Allows(shared.AlertCategoryDelay) && curr.DepEstimated != 0 && absDuration(curr.DepEstimated-depBaseline) >= prefs.DelayThreshold {
+		add(DepartureTimeChanged, fmt.Sprintf("departure_time_change_%d", curr.DepEstimated), depBaseline)
+		sub.LastAnnouncedDepEstimated = curr.DepEstimated
+	}
+	// check if the aircraft flying in is late enough to delay the departure, before the airline says so
+	// (announced again each time it gets another 15 minutes later)
+	if steps := inboundLateSteps(curr); steps > 0 && curr.InboundDelay() >= prefs.DelayThreshold {
+		add(InboundAircraftLate, fmt.Sprintf("inbound_aircraft_late_%d", steps-1), 0)
+	}
+	// check if gate was updated
+	if prev.OriginGate != curr.OriginGate && curr.OriginGate != "" {
+		add(GateChanged, fmt.Sprintf("gate_change_%s", curr.OriginGate), 0)
+	}
+	// check if arrival gate was updated
+	if prev.DestGate != curr.DestGate {
+		add(ArrivalGateChanged, fmt.Sprintf("arrival_gate_change_%s", curr.DestGate), 0)
+	}
+	// check if the terminals were updated, a terminal showing up not being a change
+	if terminalChanged(prev.OriginTerminal, curr.OriginTerminal) {
+		add(TerminalChanged, fmt.Sprintf("terminal_change_%s", curr.OriginTerminal), 0)
+	}
+	if terminalChanged(prev.DestTerminal, curr.DestTerminal) {
+		add(ArrivalTerminalChanged, fmt.Sprintf("arrival_terminal_change_%s", curr.DestTerminal), 0)
+	}
+	// check if arrival time is updated (by at least the delay threshold)
+	if arrBaseline := lastAnnounced(sub.LastAnnouncedArrEstimated, prev.ArrEstimated); prefs.Allows(shared.AlertCategoryDelay) && curr.ArrEstimated != 0 && absDuration(curr.ArrEstimated-arrBaseline) >= prefs.DelayThreshold {
+		add(ArrivalTimeChanged, fmt.Sprintf("arrival_time_change_%d", curr.ArrEstimated), arrBaseline)
+		sub.LastAnnouncedArrEstimated = curr.ArrEstimated
+	}
+
+	return alerts, sub
+}
+
+// DetectConnection returns the alerts to send about a connection of a trip, to the subscriber of its outbound flight f.
+// A tight connection is announced again each time it loses another 15 minutes, and once more when it drops
+// below the minimum connection time of the airport.
+func DetectConnection(f shared.Flight, sub shared.Subscription, c shared.Connection, prefs shared.Preferences, state *shared.FlightState, now time.Time, sent SentFunc) []FlightEvent {
+	t, alertID := connectionAlert(c)
+	if t == "" || !prefs.Allows(t.Category()) || sent(sub.ID, alertID) {
+		return nil
+	}
+
+	if state == nil {
+		state = &shared.FlightState{FlightID: f.ID}
+	}
+	event := newEvent(t, f, state, state, now)
+	event.Subscription = &sub
+	event.AlertID = alertID
+	event.Connection = &c
+	return []FlightEvent{event}
+}
+
+// DetectFlightConnection compares two states of a connection of a trip and returns the flight-wide event
+// of its outbound flight f when the risk got worth another alert in between.
+func DetectFlightConnection(f shared.Flight, prev shared.Connection, c shared.Connection, state *shared.FlightState, now time.Time) []FlightEvent {
+	t, alertID := connectionAlert(c)
+	if _, prevAlertID := connectionAlert(prev); t == "" || alertID == prevAlertID {
+		return nil
+	}
+
+	if state == nil {
+		state = &shared.FlightState{FlightID: f.ID}
+	}
+	event := newEvent(t, f, state, state, now)
+	event.Connection = &c
+	return []FlightEvent{event}
+}
+
+// connectionAlert returns the event type and alert id of the risk of a connection, no type when it isn't worth an alert
+func connectionAlert(c shared.Connection) (Type, string) {
+	if c.Departed {
+		return "", ""
+	}
+	switch c.Risk() {
+	case shared.ConnectionRiskMissed:
+		return ConnectionMissed, "connection_missed"
+	case shared.ConnectionRiskHigh:
+		return ConnectionAtRisk, "connection_at_risk"
+	case shared.ConnectionRiskTight:
+		return ConnectionTight, fmt.Sprintf("connection_tight_%d", c.Buffer()/(15*time.Minute))
+	}
+	return "", ""
+}
+
+// the aircraft needs this long at the gate between two flights
+const MinimumTurnaround = 30 * time.Minute
+
+// inboundDelaysDeparture tells whether the aircraft arrives too late to leave at the expected departure time
+func inboundDelaysDeparture(curr *shared.FlightState) bool {
+	departure := lastAnnounced(curr.DepEstimated, curr.DepScheduled)
+	return departure != 0 && curr.InboundArrival()+int64(MinimumTurnaround/time.Second) > departure
+}
+
+// inboundLateSteps counts the started quarters of an hour the aircraft flying in is late by,
+// 0 when it doesn't delay the departure (or the flight left already)
+func inboundLateSteps(curr *shared.FlightState) int64 {
+	late := curr.InboundDelay()
+	if curr.DepActual != 0 || late <= 0 || !inboundDelaysDeparture(curr) {
+		return 0
+	}
+	return late/int64(15*time.Minute/time.Second) + 1
+}
+
+// terminalChanged tells whether a known terminal was replaced by another one
+func terminalChanged(prev, curr string) bool {
+	return prev != "" && curr != "" && prev != curr
+}
+
+// utils to calculate tresholds
+func lastAnnounced(announced, fallback int64) int64 {
+	if announced != 0 {
+		return announced
+	}
+	return fallback
+}
+
+func absDuration(d int64) int64 {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"flight-tracker-slack/shared"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDetectAlerts(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	departure := now.Add(2 * time.Hour).Unix()
+	minutes := func(m int64) int64 { return m * 60 }
+
+	tests := []struct {
+		name string
+		prev shared.FlightState
+		curr shared.FlightState
+		want []string
+	}{
+		{
+			name: "gate announced",
+			prev: shared.FlightState{},
+			curr: shared.FlightState{OriginGate: "A1"},
+			want: []string{"departure_gate_announced", "gate_change_A1"},
+		},
+		{
+			name: "gate changed",
+			prev: shared.FlightState{OriginGate: "A1"},
+			curr: shared.FlightState{OriginGate: "B2"},
+			want: []string{"departure_gate_announced", "gate_change_B2"},
+		},
+		{
+			name: "gate unchanged",
+			prev: shared.FlightState{OriginGate: "A1"},
+			curr: shared.FlightState{OriginGate: "A1"},
+			want: []string{"departure_gate_announced"},
+		},
+		{
+			name: "delay below the threshold",
+			prev: shared.FlightState{DepEstimated: departure},
+			curr: shared.FlightState{DepEstimated: departure + minutes(10)},
+			want: nil,
+		},
+		{
+			name: "delay at the threshold",
+			prev: shared.FlightState{DepEstimated: departure},
+			curr: shared.FlightState{DepEstimated: departure + minutes(15)},
+			want: []string{"departure_time_change_" + strconv.FormatInt(departure+minutes(15), 10)},
+		},
+		{
+			name: "arrived, nothing else is sent",
+			prev: shared.FlightState{OriginGate: "A1", DestGate: "C3", ArrEstimated: departure},
+			curr: shared.FlightState{OriginGate: "A1", DestGate: "D4", ArrEstimated: departure + minutes(30), ArrActual: departure + minutes(30)},
+			want: []string{"departure_gate_announced", "flight_arrived_at_gate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := shared.Flight{ID: "flight", FlightNumber: "AF123", Departure: departure}
+			sub := shared.Subscription{ID: "sub", FlightID: f.ID}
+			prefs := shared.DefaultPreferences("T")
+			never := func(string, string) bool { return false }
+
+			alerts, _ := DetectAlerts(f, sub, prefs, &tt.prev, &tt.curr, now, never)
+			var got []string
+			for _, alert := range alerts {
+				got = append(got, alert.AlertID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got alerts %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFlightInboundAircraftLate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	departure := now.Add(time.Hour).Unix()
+	// the aircraft is due 45 minutes before the departure, leaving 15 minutes of turnaround to spare
+	inbound := func(late int64) shared.FlightState {
+		scheduled := departure - 45*60
+		return shared.FlightState{DepScheduled: departure, InboundArrScheduled: scheduled, InboundArrEstimated: scheduled + late*60}
+	}
+
+	tests := []struct {
+		name string
+		prev shared.FlightState
+		curr shared.FlightState
+		want bool
+	}{
+		{name: "late without delaying the departure", prev: inbound(0), curr: inbound(10), want: false},
+		{name: "starts delaying the departure", prev: inbound(10), curr: inbound(20), want: true},
+		{name: "a few more minutes late", prev: inbound(20), curr: inbound(25), want: false},
+		{name: "another quarter of an hour late", prev: inbound(25), curr: inbound(35), want: true},
+		{name: "catching up", prev: inbound(35), curr: inbound(20), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := shared.Flight{ID: "flight", FlightNumber: "AF123", Departure: departure}
+			var got bool
+			for _, event := range DetectFlight(f, &tt.prev, &tt.curr, now) {
+				got = got || event.Type == InboundAircraftLate
+			}
+			if got != tt.want {
+				t.Errorf("got inbound aircraft late %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFlightConnection(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	departure := now.Add(3 * time.Hour)
+	// JFK asks for an hour, so a connection is tight below an hour and a half
+	connection := func(buffer time.Duration) shared.Connection {
+		return shared.Connection{
+			Airport:     "JFK",
+			Arrival:     departure.Add(-buffer).Unix(),
+			Departure:   departure.Unix(),
+			MinimumTime: time.Hour,
+		}
+	}
+
+	tests := []struct {
+		name string
+		prev shared.Connection
+		curr shared.Connection
+		want Type
+	}{
+		{name: "still comfortable", prev: connection(3 * time.Hour), curr: connection(2 * time.Hour)},
+		{name: "gets tight", prev: connection(2 * time.Hour), curr: connection(80 * time.Minute), want: ConnectionTight},
+		{name: "a few minutes tighter", prev: connection(80 * time.Minute), curr: connection(76 * time.Minute)},
+		{name: "another quarter of an hour tighter", prev: connection(80 * time.Minute), curr: connection(70 * time.Minute), want: ConnectionTight},
+		{name: "below the minimum", prev: connection(70 * time.Minute), curr: connection(40 * time.Minute), want: ConnectionAtRisk},
+		{name: "missed", prev: connection(40 * time.Minute), curr: connection(-10 * time.Minute), want: ConnectionMissed},
+		{name: "missed still", prev: connection(-10 * time.Minute), curr: connection(-20 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := shared.Flight{ID: "outbound", FlightNumber: "DL100", Departure: departure.Unix()}
+			var got Type
+			for _, event := range DetectFlightConnection(f, tt.prev, tt.curr, nil, now) {
+				if event.Subscription != nil || event.Connection == nil {
+					t.Fatalf("got event %+v, want a flight-wide connection event", event)
+				}
+				got = event.Type
+			}
+			if got != tt.want {
+				t.Errorf("got event %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
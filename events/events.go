@@ -0,0 +1,149 @@
+package events
+
+import (
+	"flight-tracker-slack/flights"
+	"flight-tracker-slack/shared"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+// event types, also used as the webhook event names
+const (
+	GateAnnounced          Type = "gate.announced"
+	GateChanged            Type = "gate.changed"
+	DepartureTimeChanged   Type = "departure_time.changed"
+	Departed               Type = "flight.departed"
+	TookOff                Type = "flight.took_off"
+	InFlightUpdate         Type = "flight.in_flight_update"
+	Landed                 Type = "flight.landed"
+	Arrived                Type = "flight.arrived"
+	ArrivalGateChanged     Type = "arrival_gate.changed"
+	ArrivalTimeChanged     Type = "arrival_time.changed"
+	TerminalChanged        Type = "terminal.changed"
+	ArrivalTerminalChanged Type = "arrival_terminal.changed"
+	BaggageClaimAssigned   Type = "baggage_claim.assigned"
+	InboundAircraftLate    Type = "inbound_aircraft.late"
+	ConnectionTight        Type = "connection.tight"
+	ConnectionAtRisk       Type = "connection.at_risk"
+	ConnectionMissed       Type = "connection.missed"
+)
+
+// alert template and preference category of each event type
+var alertKinds = map[Type]struct {
+	Template string
+	Category string
+}{
+	GateAnnounced:          {"departure_gate_announced", shared.AlertCategoryGate},
+	GateChanged:            {"gate_change", shared.AlertCategoryGate},
+	DepartureTimeChanged:   {"departure_time_change", shared.AlertCategoryDelay},
+	Departed:               {"flight_departed_from_gate", shared.AlertCategoryTakeoff},
+	TookOff:                {"flight_takeoff", shared.AlertCategoryTakeoff},
+	InFlightUpdate:         {"in_flight_update", shared.AlertCategoryInFlight},
+	Landed:                 {"flight_landed", shared.AlertCategoryLanding},
+	Arrived:                {"flight_arrived_at_gate", shared.AlertCategoryLanding},
+	ArrivalGateChanged:     {"arrival_gate_change", shared.AlertCategoryGate},
+	ArrivalTimeChanged:     {"arrival_time_change", shared.AlertCategoryDelay},
+	TerminalChanged:        {"terminal_change", shared.AlertCategoryGate},
+	ArrivalTerminalChanged: {"arrival_terminal_change", shared.AlertCategoryGate},
+	BaggageClaimAssigned:   {"baggage_claim", shared.AlertCategoryLanding},
+	InboundAircraftLate:    {"inbound_aircraft_late", shared.AlertCategoryDelay},
+	ConnectionTight:        {"connection_tight", shared.AlertCategoryDelay},
+	ConnectionAtRisk:       {"connection_at_risk", shared.AlertCategoryDelay},
+	ConnectionMissed:       {"connection_missed", shared.AlertCategoryDelay},
+}
+
+// Template returns the name of the alert template of an event type
+func (t Type) Template() string {
+	return alertKinds[t].Template
+}
+
+// Category returns the preference category of an event type
+func (t Type) Category() string {
+	return alertKinds[t].Category
+}
+
+// FlightEvent is something that happened to a tracked flight.
+// Flight-wide events (for webhooks, logs...) have no Subscription, while alerts are
+// addressed to a single subscriber, after its preferences were applied.
+type FlightEvent struct {
+	ID           string
+	Type         Type
+	Flight       shared.Flight
+	Subscription *shared.Subscription
+	AlertID      string // alerts only, unique per subscriber (e.g. "gate_change_K46"), used to send them once
+	State        shared.FlightState
+	Prev         shared.FlightState
+	Previous     int64    // previously announced estimate, for time changes
+	Channels     []string // flight-wide events only, where the flight is followed
+	Detail       *flights.FlightDetail
+	Connection   *shared.Connection // connection events only, the flight being the outbound one
+	CreatedAt    time.Time
+}
+
+func newEvent(t Type, f shared.Flight, prev *shared.FlightState, curr *shared.FlightState, now time.Time) FlightEvent {
+	return FlightEvent{
+		ID:        uuid.New().String(),
+		Type:      t,
+		Flight:    f,
+		State:     *curr,
+		Prev:      *prev,
+		CreatedAt: now,
+	}
+}
+
+type Handler func(event FlightEvent)
+
+type sink struct {
+	name   string
+	handle Handler
+}
+
+// Bus delivers every published event to its sinks (slack, webhooks, logs...)
+type Bus struct {
+	mu    sync.RWMutex
+	sinks []sink
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(name string, handle Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks = append(b.sinks, sink{name: name, handle: handle})
+}
+
+// Publish calls the sinks one after the other, in the order they subscribed.
+// It is synchronous, so sinks needing time should hand the event to a goroutine,
+// and a panicking sink doesn't prevent the others from getting the event.
+func (b *Bus) Publish(event FlightEvent) {
+	b.mu.RLock()
+	sinks := b.sinks
+	b.mu.RUnlock()
+
+	for _, s := range sinks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Sink %s panicked on event %s (%s): %v", s.name, event.ID, event.Type, r)
+				}
+			}()
+			s.handle(event)
+		}()
+	}
+}
+
+// LogSink logs every event
+func LogSink(event FlightEvent) {
+	if event.Subscription != nil {
+		log.Printf("Event %s for flight %s: alert %s to %s", event.Type, event.Flight.ID, event.AlertID, event.Subscription.SlackChannel)
+		return
+	}
+	log.Printf("Event %s for flight %s", event.Type, event.Flight.ID)
+}
@@ -0,0 +1,119 @@
+package eventsapi
+
+import (
+	"encoding/json"
+	"flight-tracker-slack/home"
+	"flight-tracker-slack/shared"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// HandleEvent handles the requests of the slack Events API
+func HandleEvent(w http.ResponseWriter, r *http.Request, config shared.Config) {
+	// verify the request
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sv, err := slack.NewSecretsVerifier(r.Header, config.SigningSecret)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := sv.Write(body); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := sv.Ensure(); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// the signature was checked, the verification token is deprecated
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("Error parsing event:", err)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(verification.Challenge))
+	case slackevents.CallbackEvent:
+		// slack wants an answer within 3 seconds, events are handled in the background
+		w.WriteHeader(http.StatusOK)
+
+		if r.Header.Get("X-Slack-Retry-Num") != "" {
+			log.Printf("Received retry %s of an event (%s)\n", r.Header.Get("X-Slack-Retry-Num"), r.Header.Get("X-Slack-Retry-Reason"))
+		}
+		go DispatchEvent(event, config)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// DispatchEvent handles a callback event, whatever transport it came from
+func DispatchEvent(event slackevents.EventsAPIEvent, config shared.Config) {
+	// slack retries events it didn't get an answer for in time, they must only be handled once
+	if callback, ok := event.Data.(*slackevents.EventsAPICallbackEvent); ok && alreadySeen(callback.EventID) {
+		log.Printf("Ignoring event %s, already handled\n", callback.EventID)
+		return
+	}
+
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppHomeOpenedEvent:
+		if ev.Tab != "home" {
+			return
+		}
+		home.Publish(ev.User, config)
+	case *slackevents.AppMentionEvent:
+		handleMention(ev, config)
+	case *slackevents.LinkSharedEvent:
+		handleLinkShared(ev, config)
+	}
+}
+
+// event ids are remembered longer than slack retries them (about an hour for the last one)
+const seenEventTTL = 2 * time.Hour
+
+var (
+	seenMu     sync.Mutex
+	seenEvents = make(map[string]time.Time)
+)
+
+// alreadySeen records an event id, telling whether it was already received
+func alreadySeen(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+
+	seenMu.Lock()
+	defer seenMu.Unlock()
+
+	now := time.Now()
+	for id, at := range seenEvents {
+		if now.Sub(at) > seenEventTTL {
+			delete(seenEvents, id)
+		}
+	}
+	if _, ok := seenEvents[eventID]; ok {
+		return true
+	}
+	seenEvents[eventID] = now
+	return false
+}
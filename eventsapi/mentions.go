@@ -0,0 +1,150 @@
+package eventsapi
+
+import (
+	"flight-tracker-slack/commands"
+	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// matches user mentions like <@U123ABC> or <@U123ABC|alice>
+var mentionPattern = regexp.MustCompile(`<@[UW][A-Z0-9]+(\|[^>]*)?>`)
+
+// words telling which command a mention is about, in every supported language
+var mentionIntents = map[string]string{
+	"track":    "track-flight",
+	"follow":   "track-flight",
+	"suivre":   "track-flight",
+	"suis":     "track-flight",
+	"untrack":  "untrack-flight",
+	"unfollow": "untrack-flight",
+	"stop":     "untrack-flight",
+	"list":     "list-flights",
+	"liste":    "list-flights",
+	"help":     "flights-help",
+	"aide":     "flights-help",
+	"where":    "flight-info",
+	"info":     "flight-info",
+	"status":   "flight-info",
+	"où":       "flight-info",
+	"statut":   "flight-info",
+}
+
+// relative dates understood by /track-flight
+var mentionDates = map[string]string{
+	"today":       "today",
+	"tomorrow":    "tomorrow",
+	"aujourd'hui": "today",
+	"demain":      "tomorrow",
+}
+
+// handleMention answers "@flights where is AF102?" or "@flights track BA117 tomorrow"
+// by running the matching slash command
+func handleMention(ev *slackevents.AppMentionEvent, config shared.Config) {
+	if ev.BotID != "" {
+		return
+	}
+
+	name, text := parseMention(ev.Text)
+	command := slack.SlashCommand{
+		Command:   "/" + name,
+		Text:      text,
+		UserID:    ev.User,
+		ChannelID: ev.Channel,
+	}
+	log.Printf("Mention of %s in %s handled as %s %q\n", ev.User, ev.Channel, command.Command, text)
+
+	// answers go in the thread of the mention
+	thread := ev.ThreadTimeStamp
+	if thread == "" {
+		thread = ev.TimeStamp
+	}
+	locale := shared.ResolveLocale(ev.Channel, ev.User, config)
+
+	for _, cmd := range commands.CommandList {
+		if cmd.Name != name {
+			continue
+		}
+		blocks, inChannel, after := cmd.Execute(command, config)
+		if len(blocks) > 0 {
+			var err error
+			if inChannel {
+				_, _, err = config.SlackClient.PostMessage(ev.Channel, slack.MsgOptionBlocks(blocks...), slack.MsgOptionTS(thread))
+			} else {
+				_, err = config.SlackClient.PostEphemeral(ev.Channel, ev.User, slack.MsgOptionBlocks(blocks...), slack.MsgOptionTS(thread))
+			}
+			if err != nil {
+				log.Printf("Error answering mention of %s: %v\n", ev.User, err)
+			}
+		}
+		if after != nil {
+			if err := after(); err != nil {
+				log.Printf("after function error: %v", err)
+				config.SlackClient.PostEphemeral(ev.Channel, ev.User, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, err, i18n.T(locale, "error.command_failed"))...), slack.MsgOptionTS(thread))
+			}
+		}
+		return
+	}
+}
+
+// parseMention turns the text of a mention into a command and its arguments.
+// Without any known word, a flight number means /flight-info and anything else /flights-help.
+func parseMention(text string) (string, string) {
+	// the first mention is the bot itself, others are kept (e.g. "for @alice")
+	if loc := mentionPattern.FindStringIndex(text); loc != nil && strings.TrimSpace(text[:loc[0]]) == "" {
+		text = text[loc[1]:]
+	}
+
+	var name, flightNumber, date string
+	var extra []string
+	words := strings.Fields(text)
+	for i := 0; i < len(words); i++ {
+		word := strings.Trim(words[i], "?!.,;:")
+		lower := strings.ToLower(word)
+
+		switch {
+		case mentionIntents[lower] != "" && name == "":
+			name = mentionIntents[lower]
+		case mentionDates[lower] != "":
+			date = mentionDates[lower]
+		case flights.FlightNumPattern.MatchString(strings.ToUpper(word)) && flightNumber == "":
+			flightNumber = strings.ToUpper(word)
+		case lower == "dm":
+			extra = append(extra, "dm")
+		case (lower == "for" || lower == "pour") && i+1 < len(words) && mentionPattern.MatchString(words[i+1]):
+			extra = append(extra, "for", strings.Trim(words[i+1], "?!.,;:"))
+			i++
+		default:
+			if _, err := time.Parse(time.DateOnly, word); err == nil {
+				date = word
+			}
+		}
+	}
+
+	if name == "" {
+		name = "flights-help"
+		if flightNumber != "" {
+			name = "flight-info"
+		}
+	}
+
+	switch name {
+	case "track-flight":
+		args := []string{flightNumber}
+		if date != "" {
+			args = append(args, date)
+		}
+		return name, strings.Join(append(args, extra...), " ")
+	case "untrack-flight", "flight-info":
+		return name, flightNumber
+	default:
+		return name, ""
+	}
+}
@@ -0,0 +1,30 @@
+package eventsapi
+
+import "testing"
+
+func TestParseMention(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		args string
+	}{
+		{"<@UBOT> where is AF102?", "flight-info", "AF102"},
+		{"<@UBOT> af102", "flight-info", "AF102"},
+		{"<@UBOT> track BA117 tomorrow", "track-flight", "BA117 tomorrow"},
+		{"<@UBOT> suivre AF102 demain dm", "track-flight", "AF102 tomorrow dm"},
+		{"<@UBOT> track BA117 2026-11-03 for <@U123ABC>", "track-flight", "BA117 2026-11-03 for <@U123ABC>"},
+		{"<@UBOT> track BA117 for alice", "track-flight", "BA117"},
+		{"<@UBOT> stop tracking AF102", "untrack-flight", "AF102"},
+		{"<@UBOT> list", "list-flights", ""},
+		{"<@UBOT> hello there", "flights-help", ""},
+		{"hey <@UBOT> status of AF102", "flight-info", "AF102"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			name, args := parseMention(tt.text)
+			if name != tt.name || args != tt.args {
+				t.Errorf("parseMention(%q) = %q, %q, want %q, %q", tt.text, name, args, tt.name, tt.args)
+			}
+		})
+	}
+}
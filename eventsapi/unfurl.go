@@ -0,0 +1,109 @@
+package eventsapi
+
+import (
+	"errors"
+	"flight-tracker-slack/commands"
+	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// matches flightaware.com/live/flight/<ident> links, with anything after the ident
+var flightAwarePattern = regexp.MustCompile(`^https?://(?:www\.)?flightaware\.com/live/flight/([A-Za-z0-9]+)`)
+
+// handleLinkShared unfurls the links to a flight with a card like /flight-info's
+func handleLinkShared(ev *slackevents.LinkSharedEvent, config shared.Config) {
+	locale := shared.ResolveLocale(ev.Channel, ev.User, config)
+
+	unfurls := make(map[string]slack.Attachment)
+	for _, link := range ev.Links {
+		flightNumber := linkedFlight(link.URL, config)
+		if flightNumber == "" {
+			continue
+		}
+		blocks, err := unfurlBlocks(locale, flightNumber, config)
+		if err != nil {
+			log.Printf("Error unfurling %s: %v\n", link.URL, err)
+			continue
+		}
+		unfurls[link.URL] = slack.Attachment{Blocks: slack.Blocks{BlockSet: blocks}}
+	}
+	if len(unfurls) == 0 {
+		return
+	}
+
+	if _, _, _, err := config.SlackClient.UnfurlMessage(ev.Channel, ev.MessageTimeStamp, unfurls); err != nil {
+		log.Printf("Error unfurling links in %s: %v\n", ev.Channel, err)
+	}
+}
+
+// linkedFlight returns the flight number of a flightaware link or of one of our map links, "" for other links
+func linkedFlight(link string, config shared.Config) string {
+	var flightNumber string
+	if matches := flightAwarePattern.FindStringSubmatch(link); matches != nil {
+		flightNumber = matches[1]
+	} else if config.PublicURL != "" && strings.HasPrefix(link, config.PublicURL+"/map/") {
+		flightNumber, _ = url.PathUnescape(strings.Trim(strings.TrimPrefix(link, config.PublicURL+"/map/"), "/"))
+	}
+
+	flightNumber = strings.ToUpper(flightNumber)
+	if !flights.FlightNumPattern.MatchString(flightNumber) {
+		return ""
+	}
+	return flightNumber
+}
+
+func unfurlBlocks(locale string, flightNumber string, config shared.Config) ([]slack.Block, error) {
+	flightInfo, err := flights.GetFlightInfo(flightNumber)
+	if err != nil {
+		return nil, err
+	}
+	fd := flightInfo.GetFirstFlight()
+	if fd == nil || fd.Origin.Iata == "" {
+		return nil, errors.New("no active flight for " + flightNumber)
+	}
+
+	infoText, err := commands.FlightSummary(locale, *fd)
+	if err != nil {
+		return nil, err
+	}
+
+	// the map needs the bot to be reachable from slack, the airline logo is shown otherwise
+	accessory := slack.NewAccessory(slack.NewImageBlockElement(
+		"https://www.flightaware.com/images/airline_logos/180px/"+fd.Airline.Icao+".png",
+		fd.Airline.FullName,
+	))
+	if config.PublicURL != "" {
+		accessory = slack.NewAccessory(slack.NewImageBlockElement(config.PublicURL+"/map/"+url.PathEscape(flightNumber), i18n.T(locale, "home.map", flightNumber)))
+	}
+
+	// the button opens the tracking modal, like /track-flight
+	value, err := shared.EncodeAction(shared.ActionValue{Track: &shared.TrackRequest{FlightNumber: flightNumber}}, config)
+	if err != nil {
+		return nil, err
+	}
+
+	header := i18n.T(locale, "info.header", flightNumber, i18n.FormatDate(locale, time.Now()))
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "*"+header+"*\n"+infoText, false, false),
+			nil,
+			accessory,
+		),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(
+				"trackopen-button",
+				value,
+				slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "unfurl.track"), false, false),
+			).WithStyle(slack.StylePrimary),
+		),
+	}, nil
+}
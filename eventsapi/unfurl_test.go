@@ -0,0 +1,28 @@
+package eventsapi
+
+import (
+	"flight-tracker-slack/shared"
+	"testing"
+)
+
+func TestLinkedFlight(t *testing.T) {
+	config := shared.Config{PublicURL: "https://flights.example.com"}
+
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://flightaware.com/live/flight/AFR102", "AFR102"},
+		{"https://www.flightaware.com/live/flight/af102/history/20250601", "AF102"},
+		{"https://flights.example.com/map/BA117", "BA117"},
+		{"https://flights.example.com/map/ba117/", "BA117"},
+		{"https://flights.example.com/other/BA117", ""},
+		{"https://flightaware.com/live/airport/KJFK", ""},
+		{"https://example.com/live/flight/AF102", ""},
+	}
+	for _, tt := range tests {
+		if got := linkedFlight(tt.link, config); got != tt.want {
+			t.Errorf("linkedFlight(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
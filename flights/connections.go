@@ -0,0 +1,48 @@
+package flights
+
+import (
+	"encoding/json"
+	"flight-tracker-slack/assets"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const connectionTimesPath = "connection_times.json"
+
+// ConnectionTimes are the minimum connection times of an airport, in minutes
+type ConnectionTimes struct {
+	SameTerminal  int `json:"same_terminal"`
+	OtherTerminal int `json:"other_terminal"`
+}
+
+var connectionTimes struct {
+	Default  ConnectionTimes            `json:"default"`
+	Airports map[string]ConnectionTimes `json:"airports"` // by iata code
+}
+
+// embedded in the binary, so it only fails on a broken table
+func init() {
+	body, err := assets.Files.ReadFile(connectionTimesPath)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(body, &connectionTimes); err != nil {
+		panic(fmt.Errorf("invalid connection times: %w", err))
+	}
+}
+
+// MinimumConnectionTime returns the time needed to change planes at an airport, between two terminals.
+// Unknown terminals are assumed to be different, to stay on the safe side.
+func MinimumConnectionTime(airport string, arrivalTerminal string, departureTerminal string) time.Duration {
+	times, ok := connectionTimes.Airports[strings.ToUpper(airport)]
+	if !ok {
+		times = connectionTimes.Default
+	}
+
+	minutes := times.OtherTerminal
+	if arrivalTerminal != "" && strings.EqualFold(arrivalTerminal, departureTerminal) {
+		minutes = times.SameTerminal
+	}
+	return time.Duration(minutes) * time.Minute
+}
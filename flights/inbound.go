@@ -0,0 +1,33 @@
+package flights
+
+import "time"
+
+// InboundFlight returns the previous leg flown by the aircraft of a flight, found from its registration.
+// It's nil when the aircraft isn't assigned yet, or isn't flying to the origin of the flight yet.
+func InboundFlight(fd *FlightDetail) (*FlightDetail, error) {
+	if fd.Aircraft.Tail == "" || fd.Origin.Iata == "" {
+		return nil, nil
+	}
+
+	data, err := GetAircraftInfo(fd.Aircraft.Tail)
+	if err != nil {
+		return nil, err
+	}
+
+	// the page may list several rotations of the aircraft: the inbound leg is the last one landing before the departure
+	departure := fd.GetSchedule().DepartureScheduled
+	var inbound *FlightDetail
+	var inboundArrival time.Time
+	for _, flight := range data.Flights {
+		if flight.Destination.Iata != fd.Origin.Iata {
+			continue
+		}
+		// the aircraft page may show the flight itself, once the aircraft is at the gate
+		arrival := flight.GetSchedule().ArrivalScheduled
+		if arrival.IsZero() || arrival.After(departure) || !arrival.After(inboundArrival) {
+			continue
+		}
+		inbound, inboundArrival = &flight, arrival
+	}
+	return inbound, nil
+}
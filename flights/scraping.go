@@ -38,6 +38,18 @@ func GetFlightInfo(flightNumber string) (FlightDataWrapper, error) {
 		return FlightDataWrapper{}, err
 	}
 
+	return fetchTrackpoll(flightNumber)
+}
+
+// GetAircraftInfo returns the flights of an aircraft, the one it is flying (or about to fly) first
+func GetAircraftInfo(registration string) (FlightDataWrapper, error) {
+	// flightaware identifies aircraft by their registration without dashes, e.g. "FHRBA" for F-HRBA
+	registration = strings.ToUpper(strings.ReplaceAll(registration, "-", ""))
+	return fetchTrackpoll(registration)
+}
+
+// fetchTrackpoll loads the flight page of an ident (flight number or registration) and parses its data
+func fetchTrackpoll(ident string) (FlightDataWrapper, error) {
 	client := &http.Client{
 		Timeout: 10 * time.Second,
 	}
@@ -45,7 +57,7 @@ func GetFlightInfo(flightNumber string) (FlightDataWrapper, error) {
 		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
 	}
 
-	req, err := http.NewRequest("GET", "https://flightaware.com/live/flight/"+flightNumber, nil)
+	req, err := http.NewRequest("GET", "https://flightaware.com/live/flight/"+ident, nil)
 	if err != nil {
 		return FlightDataWrapper{}, err
 	}
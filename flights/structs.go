@@ -1,6 +1,9 @@
 package flights
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -41,6 +44,7 @@ type TrackPoint struct {
 type AircraftDetail struct {
 	FriendlyType string `json:"friendlyType"`
 	Type         string `json:"type"`
+	Tail         string `json:"tail"` // registration, once the airline assigned the aircraft
 }
 
 type AirlineDetail struct {
@@ -60,6 +64,7 @@ type AirportDetail struct {
 	Iata             string     `json:"iata"`
 	Icao             string     `json:"icao"`
 	Terminal         string     `json:"terminal"`
+	Baggage          *string    `json:"baggage"` // baggage claim belt, arrivals only; undocumented key, nil when the payload doesn't carry it
 	Delays           []struct {
 		Reason string `json:"reason"`
 		Time   string `json:"time"`
@@ -67,6 +72,14 @@ type AirportDetail struct {
 	} `json:"delays"`
 }
 
+// BaggageClaim returns the baggage claim belt, empty if not given
+func (a AirportDetail) BaggageClaim() string {
+	if a.Baggage == nil {
+		return ""
+	}
+	return *a.Baggage
+}
+
 type DistanceDetail struct {
 	Actual    *int `json:"actual"`
 	Elapsed   int  `json:"elapsed"`
@@ -146,3 +159,107 @@ func (f *FlightDataWrapper) GetFirstFlight() *FlightDetail {
 	}
 	return nil
 }
+
+// Leg is a scheduled flight between two airports, offered when tracking a flight number
+type Leg struct {
+	Origin      string        // iata code
+	Destination string        // iata code
+	Timezone    string        // of the origin airport
+	Departure   time.Time     // scheduled departure, in the origin timezone
+	Duration    time.Duration // scheduled from gate to gate, 0 if unknown
+}
+
+// Legs returns the legs found for a flight number, by time of departure
+func (f *FlightDataWrapper) Legs() []Leg {
+	return uniqueLegs(f.scheduledLegs())
+}
+
+// LegsOn returns the legs departing on a given day, in their origin timezone.
+// Days the upstream data doesn't cover are assumed to follow the usual schedule.
+func (f *FlightDataWrapper) LegsOn(date time.Time) []Leg {
+	day := date.Format(time.DateOnly)
+	var legs []Leg
+	for _, leg := range f.scheduledLegs() {
+		if leg.Departure.Format(time.DateOnly) == day {
+			legs = append(legs, leg)
+		}
+	}
+	if len(legs) == 0 {
+		return f.Legs()
+	}
+	return uniqueLegs(legs)
+}
+
+// scheduledLegs returns one leg per flight of the upstream data, dated
+func (f *FlightDataWrapper) scheduledLegs() []Leg {
+	var legs []Leg
+	for _, flight := range f.Flights {
+		if flight.GateDepartureTimes.Scheduled == nil || flight.Origin.Iata == "" {
+			continue
+		}
+		tz := strings.TrimPrefix(flight.Origin.TZ, ":")
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			loc = time.UTC
+			tz = "UTC"
+		}
+		leg := Leg{
+			Origin:      flight.Origin.Iata,
+			Destination: flight.Destination.Iata,
+			Timezone:    tz,
+			Departure:   time.Unix(*flight.GateDepartureTimes.Scheduled, 0).In(loc),
+		}
+		if arrival := flight.GateArrivalTimes.Scheduled; arrival != nil {
+			leg.Duration = time.Unix(*arrival, 0).Sub(leg.Departure)
+		}
+		legs = append(legs, leg)
+	}
+	return legs
+}
+
+// uniqueLegs drops the legs repeating at the same time of day, sorting them by time of departure
+func uniqueLegs(all []Leg) []Leg {
+	var legs []Leg
+	seen := make(map[string]bool)
+	for _, leg := range all {
+		key := leg.Value()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		legs = append(legs, leg)
+	}
+
+	sort.Slice(legs, func(i, j int) bool {
+		return legs[i].Departure.Format("15:04") < legs[j].Departure.Format("15:04")
+	})
+	return legs
+}
+
+// Value encodes a leg as "CDG-JFK|10:30|Europe/Paris", to be found again with ParseLeg
+func (l Leg) Value() string {
+	return l.Origin + "-" + l.Destination + "|" + l.Departure.Format("15:04") + "|" + l.Timezone
+}
+
+// ParseLeg decodes a leg encoded by Value
+func ParseLeg(value string) (Leg, error) {
+	parts := strings.SplitN(value, "|", 3)
+	if len(parts) < 3 {
+		return Leg{}, fmt.Errorf("invalid leg %q", value)
+	}
+	origin, destination, _ := strings.Cut(parts[0], "-")
+	loc, err := time.LoadLocation(parts[2])
+	if err != nil {
+		return Leg{}, err
+	}
+	departure, err := time.ParseInLocation("15:04", parts[1], loc)
+	if err != nil {
+		return Leg{}, err
+	}
+	return Leg{Origin: origin, Destination: destination, Timezone: parts[2], Departure: departure}, nil
+}
+
+// On returns the departure of a leg on a given day, in the origin timezone
+func (l Leg) On(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), l.Departure.Hour(), l.Departure.Minute(), 0, 0, l.Departure.Location())
+}
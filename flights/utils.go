@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"regexp"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -13,10 +14,29 @@ var (
 	IcaoPattern      = regexp.MustCompile(`^[A-Z]{3}$`)                // ICAO airline code (3 uppercase letters)
 	IataPattern      = regexp.MustCompile(`^[A-Z]{2}$`)                // IATA airline code (2 uppercase letters)
 	FlightNumPattern = regexp.MustCompile(`^[A-Z]{2,3}\d{1,4}[A-Z]?$`) // Flight number pattern (e.g., "AA100", "DLH400A"
+
+	// flight numbers within some text, like "AF 102" or "DLH400" in an itinerary
+	flightNumInText = regexp.MustCompile(`\b([A-Z]{2,3}) ?(\d{1,4}[A-Z]?)\b`)
 )
 
 var db *sql.DB
 
+// Airline is an airline of the airlines database
+type Airline struct {
+	Name string
+	IATA string
+	ICAO string
+}
+
+// Airport is an airport of the airports table, the busiest ones only
+type Airport struct {
+	IATA    string
+	ICAO    string
+	Name    string
+	City    string
+	Country string
+}
+
 func init() {
 	// init the db connection
 	var err error
@@ -104,3 +124,78 @@ func ExpandFlightNumber(flight string) (string, error) {
 
 	return icao + num, nil
 }
+
+// FindFlightNumbers returns the flight numbers found in some text, in order and without duplicates.
+// Only the ones of a known airline are kept, so that other codes aren't mistaken for flights.
+func FindFlightNumbers(text string) []string {
+	var numbers []string
+	seen := make(map[string]bool)
+	for _, matches := range flightNumInText.FindAllStringSubmatch(text, -1) {
+		number := matches[1] + matches[2]
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+		if _, err := AirlineCodeToICAO(db, matches[1]); err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers
+}
+
+// SearchAirlines returns the active airlines whose name starts with the query, or whose code is the query.
+// Only airlines with a letters-only iata code are returned, as FlightNumPattern expects.
+func SearchAirlines(query string, limit int) ([]Airline, error) {
+	query = strings.TrimSpace(query)
+	rows, err := db.Query(`SELECT name, iata, icao FROM airlines
+		WHERE active = 'Y' AND iata GLOB '[A-Z][A-Z]' AND (name LIKE ? OR iata = upper(?) OR icao = upper(?))
+		ORDER BY iata = upper(?) DESC, name LIMIT ?`, query+"%", query, query, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var airlines []Airline
+	for rows.Next() {
+		var a Airline
+		if err := rows.Scan(&a.Name, &a.IATA, &a.ICAO); err != nil {
+			return nil, err
+		}
+		airlines = append(airlines, a)
+	}
+	return airlines, rows.Err()
+}
+
+// SearchAirports returns the airports whose name or city starts with the query, or whose code is the query
+func SearchAirports(query string, limit int) ([]Airport, error) {
+	query = strings.TrimSpace(query)
+	rows, err := db.Query(`SELECT iata, icao, name, city, country FROM airports
+		WHERE name LIKE ? OR city LIKE ? OR iata = upper(?) OR icao = upper(?)
+		ORDER BY iata = upper(?) DESC, city, name LIMIT ?`, query+"%", query+"%", query, query, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var airports []Airport
+	for rows.Next() {
+		var a Airport
+		if err := rows.Scan(&a.IATA, &a.ICAO, &a.Name, &a.City, &a.Country); err != nil {
+			return nil, err
+		}
+		airports = append(airports, a)
+	}
+	return airports, rows.Err()
+}
+
+// GetAirport returns an airport by its iata code
+func GetAirport(iata string) (*Airport, error) {
+	var a Airport
+	err := db.QueryRow("SELECT iata, icao, name, city, country FROM airports WHERE iata = upper(?)", iata).
+		Scan(&a.IATA, &a.ICAO, &a.Name, &a.City, &a.Country)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
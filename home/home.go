@@ -0,0 +1,207 @@
+package home
+
+import (
+	"database/sql"
+	"errors"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// phases of a flight, named after their catalog keys
+const (
+	PhaseScheduled = "scheduled"
+	PhaseDeparted  = "departed"
+	PhaseInAir     = "in_air"
+	PhaseLanded    = "landed"
+	PhaseArrived   = "arrived"
+)
+
+// Publish refreshes the Home tab of a user
+func Publish(userID string, config shared.Config) {
+	locale := shared.ResolveLocale("", userID, config)
+	if _, err := config.SlackClient.PublishView(userID, View(locale, userID, config), ""); err != nil {
+		log.Printf("Error publishing home of %s: %v", userID, err)
+	}
+}
+
+// PublishSubscribers refreshes the Home tab of each user tracking a flight, once per user
+func PublishSubscribers(subs []shared.Subscription, config shared.Config) {
+	published := make(map[string]bool)
+	for _, sub := range subs {
+		if !published[sub.SlackUserID] {
+			published[sub.SlackUserID] = true
+			Publish(sub.SlackUserID, config)
+		}
+	}
+}
+
+// View builds the Home tab of a user: the flights they track, with their phase and what comes next
+func View(locale string, userID string, config shared.Config) slack.HomeTabViewRequest {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "home.header"), false, false)),
+	}
+
+	// the user's subscriptions, grouped by flight, whoever tracked the flight first
+	subs, err := shared.GetSubscriptions(shared.SubscriptionFilter{SlackUserID: userID}, config)
+	switch {
+	case err != nil:
+		blocks = append(blocks, shared.NewErrorBlocks(locale, err)...)
+	case len(subs) == 0:
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "home.empty"), false, false),
+			nil,
+			nil,
+		))
+	}
+
+	var flightIDs []string
+	byFlight := make(map[string][]shared.Subscription)
+	for _, sub := range subs {
+		if _, exists := byFlight[sub.FlightID]; !exists {
+			flightIDs = append(flightIDs, sub.FlightID)
+		}
+		byFlight[sub.FlightID] = append(byFlight[sub.FlightID], sub)
+	}
+
+	for _, id := range flightIDs {
+		flightSubs := byFlight[id]
+		f := shared.Flight{
+			ID:           id,
+			FlightNumber: flightSubs[0].FlightNumber,
+			Departure:    flightSubs[0].Departure,
+		}
+
+		state, err := shared.GetFlightState(id, config)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error loading state of flight %s: %v", id, err)
+		}
+		blocks = append(blocks, slack.NewDividerBlock())
+		blocks = append(blocks, flightBlocks(locale, f, flightSubs, state, config)...)
+	}
+
+	now := time.Now()
+	blocks = append(blocks, slack.NewContextBlock("",
+		slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "home.updated", shared.SlackDate(now, "{date_short_pretty} {time}", now.UTC().Format(time.RFC822))), false, false),
+	))
+
+	return slack.HomeTabViewRequest{
+		Type:   slack.VTHomeTab,
+		Blocks: slack.Blocks{BlockSet: blocks},
+	}
+}
+
+func flightBlocks(locale string, f shared.Flight, subs []shared.Subscription, state *shared.FlightState, config shared.Config) []slack.Block {
+	destinations := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		destinations = append(destinations, sub.Destination())
+	}
+
+	text := i18n.T(locale, "phase."+Phase(state))
+	if next := nextEvent(locale, state, f.Departure); next != "" {
+		text += "\n" + i18n.T(locale, "home.next", next)
+	}
+
+	// the map is only shown when the bot is reachable from slack
+	var thumbnail *slack.Accessory
+	if config.PublicURL != "" {
+		thumbnail = slack.NewAccessory(slack.NewImageBlockElement(config.PublicURL+"/map/"+url.PathEscape(f.FlightNumber), i18n.T(locale, "home.map", f.FlightNumber)))
+	}
+
+	settings, err := shared.EncodeAction(shared.ActionValue{FlightID: f.ID, Channel: subs[0].SlackChannel}, config)
+	if err != nil {
+		log.Printf("Error encoding home actions of %s: %v", f.ID, err)
+	}
+	untrack, err := shared.EncodeAction(shared.ActionValue{FlightID: f.ID}, config)
+	if err != nil {
+		log.Printf("Error encoding home actions of %s: %v", f.ID, err)
+	}
+
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "home.flight", f.FlightNumber, strings.Join(destinations, ", "), text), false, false),
+			nil,
+			thumbnail,
+		),
+		// action ids only need to be unique within the view, what they apply to is in their signed value
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(
+				"home-settings-"+f.ID,
+				settings,
+				slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "home.settings"), false, false),
+			),
+			slack.NewButtonBlockElement(
+				"home-untrack-"+f.ID,
+				untrack,
+				slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "home.untrack"), false, false),
+			).WithStyle(slack.StyleDanger),
+		),
+	}
+}
+
+// Phase returns where a flight is at, scheduled if it has no state yet
+func Phase(state *shared.FlightState) string {
+	switch {
+	case state == nil:
+		return PhaseScheduled
+	case state.ArrActual != 0:
+		return PhaseArrived
+	case state.LandingActual != 0:
+		return PhaseLanded
+	case state.TakeOffActual != 0:
+		return PhaseInAir
+	case state.DepActual != 0:
+		return PhaseDeparted
+	default:
+		return PhaseScheduled
+	}
+}
+
+// nextEvent describes the next step of a flight, in the reader's time
+func nextEvent(locale string, state *shared.FlightState, departure int64) string {
+	key, at := "", int64(0)
+	switch Phase(state) {
+	case PhaseScheduled:
+		key, at = "next.departure", departure
+		if state != nil {
+			at = first(state.DepEstimated, state.DepScheduled, departure)
+		}
+	case PhaseDeparted:
+		key, at = "next.takeoff", state.TakeOffEstimated
+	case PhaseInAir:
+		key, at = "next.landing", state.LandingEstimated
+	case PhaseLanded:
+		key, at = "next.arrival", first(state.ArrEstimated, state.ArrScheduled)
+	}
+	if key == "" || at == 0 {
+		return ""
+	}
+	t := time.Unix(at, 0)
+	return i18n.T(locale, key, shared.SlackDate(t, "{date_short_pretty} {time}", t.UTC().Format(time.RFC822)))
+}
+
+// Changed tells whether the Home tab of a flight would look different between two states
+func Changed(prev *shared.FlightState, curr *shared.FlightState) bool {
+	if prev == nil || curr == nil {
+		return prev != curr
+	}
+	return Phase(prev) != Phase(curr) ||
+		prev.DepEstimated != curr.DepEstimated ||
+		prev.TakeOffEstimated != curr.TakeOffEstimated ||
+		prev.LandingEstimated != curr.LandingEstimated ||
+		prev.ArrEstimated != curr.ArrEstimated
+}
+
+func first(values ...int64) int64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package i18n
+
+import (
+	"encoding/json"
+	"flight-tracker-slack/assets"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is used when neither the channel nor the user has a supported language
+const DefaultLocale = "en"
+
+// supported locales, each with a catalog in assets/i18n, embedded in the binary
+var Locales = []string{"en", "fr"}
+
+const catalogsPath = "i18n/"
+
+var catalogs = make(map[string]map[string]string)
+
+func init() {
+	for _, locale := range Locales {
+		body, err := assets.Files.ReadFile(catalogsPath + locale + ".json")
+		if err != nil {
+			panic(err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(body, &catalog); err != nil {
+			panic(fmt.Errorf("invalid %s catalog: %w", locale, err))
+		}
+		catalogs[locale] = catalog
+	}
+}
+
+// Normalize turns a slack locale (e.g. "fr-FR") into a supported locale, or "" if it isn't supported
+func Normalize(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	if _, ok := catalogs[locale]; ok {
+		return locale
+	}
+	return ""
+}
+
+// Lookup returns the message of a key, falling back to the default locale
+func Lookup(locale, key string) (string, bool) {
+	if msg, ok := catalogs[locale][key]; ok {
+		return msg, true
+	}
+	msg, ok := catalogs[DefaultLocale][key]
+	return msg, ok
+}
+
+// T translates a key, the message being formatted with args like fmt.Sprintf.
+// Missing keys are returned as is, so they are easy to spot.
+func T(locale, key string, args ...any) string {
+	msg, ok := Lookup(locale, key)
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// LanguageName returns the name of a locale in its own language
+func LanguageName(locale string) string {
+	return T(locale, "language.name")
+}
+
+// FormatTime formats a time of day, 12-hour or 24-hour depending on the locale
+func FormatTime(locale string, t time.Time) string {
+	return t.Format(T(locale, "format.time"))
+}
+
+// FormatDate formats a day with the month name in the locale language
+func FormatDate(locale string, t time.Time) string {
+	month := T(locale, fmt.Sprintf("month.%d", int(t.Month())))
+	return T(locale, "format.date", t.Day(), month, t.Year())
+}
+
+// FormatDuration formats a duration like shared.FormatDuration, with the locale units
+func FormatDuration(locale string, d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, T(locale, "duration.days", days))
+	}
+	if hours > 0 {
+		parts = append(parts, T(locale, "duration.hours", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, T(locale, "duration.minutes", minutes))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flight-tracker-slack/flights"
+	"flight-tracker-slack/shared"
+	"log"
+	"time"
+)
+
+// the inbound aircraft costs another scrape, so it's only looked up this often (or when the aircraft changes)
+const inboundLookupEvery = 10 * time.Minute
+
+// inboundAircraft fills the state with the previous leg of the aircraft, looked up until the flight
+// leaves the gate, and kept as is once the aircraft arrived (or until it's swapped for another one)
+func (b *LogicLoop) inboundAircraft(prev *shared.FlightState, curr *shared.FlightState, detail *flights.FlightDetail) {
+	swapped := prev == nil || prev.Tail != curr.Tail
+	if !swapped {
+		curr.InboundOrigin = prev.InboundOrigin
+		curr.InboundArrScheduled = prev.InboundArrScheduled
+		curr.InboundArrEstimated = prev.InboundArrEstimated
+		curr.InboundArrActual = prev.InboundArrActual
+		curr.InboundCheckedAt = prev.InboundCheckedAt
+	}
+	if curr.Tail == "" || curr.DepActual != 0 || curr.InboundArrActual != 0 {
+		return
+	}
+	now := time.Now()
+	if !swapped && now.Sub(time.Unix(curr.InboundCheckedAt, 0)) < inboundLookupEvery {
+		return
+	}
+	curr.InboundCheckedAt = now.Unix()
+
+	inbound, err := flights.InboundFlight(detail)
+	if err != nil {
+		log.Printf("Error looking up the inbound aircraft of flight %s (%s): %v", curr.FlightID, curr.Tail, err)
+		return
+	}
+	if inbound != nil {
+		shared.SetInboundFlight(curr, inbound)
+	}
+}
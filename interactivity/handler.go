@@ -14,29 +14,50 @@ import (
 
 var InteractionList []shared.Interaction = []shared.Interaction{
 	TrackInteraction,
+	TrackOpenInteraction,
 	UntrackInteraction,
+	UntrackSelectInteraction,
+	SettingsInteraction,
+	TemplatesInteraction,
+	HomeInteraction,
+	TrackShortcutInteraction,
 }
 
 func HandleInteraction(w http.ResponseWriter, r *http.Request, config shared.Config) {
-	// verify the request
+	payload, ok := parsePayload(w, r, config)
+	if !ok {
+		return
+	}
 
+	response := DispatchInteraction(payload, config)
+	if response != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// parsePayload verifies a request from slack and decodes its payload, answering it when it's invalid
+func parsePayload(w http.ResponseWriter, r *http.Request, config shared.Config) (slack.InteractionCallback, bool) {
+	var payload slack.InteractionCallback
+
+	// verify the request
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return payload, false
 	}
 	sv, err := slack.NewSecretsVerifier(r.Header, config.SigningSecret)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return payload, false
 	}
 	if _, err := sv.Write(body); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return payload, false
 	}
 	if err := sv.Ensure(); err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
-		return
+		return payload, false
 	}
 
 	r.Body = io.NopCloser(bytes.NewBuffer(body))
@@ -45,32 +66,92 @@ func HandleInteraction(w http.ResponseWriter, r *http.Request, config shared.Con
 	err = r.ParseForm()
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return payload, false
 	}
 	payloadJSON := r.PostForm.Get("payload")
 	println("Received interaction payload:", payloadJSON)
 
-	var payload slack.InteractionCallback
 	err = json.Unmarshal([]byte(payloadJSON), &payload)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		log.Println("Error unmarshaling interaction payload:", err)
-		return
+		return payload, false
 	}
+	return payload, true
+}
 
+// DispatchInteraction routes an interaction payload to its handlers, whatever transport it came from,
+// by the prefix (before "-") of its action id or callback id.
+// The returned response, if any, is what slack expects as the answer: the validation errors
+// of a view submission, or the options of an external select.
+func DispatchInteraction(payload slack.InteractionCallback, config shared.Config) any {
 	switch payload.Type {
 	case slack.InteractionTypeBlockActions:
-		// separate actionId by "-"
-		args := strings.Split(payload.ActionCallback.BlockActions[0].ActionID, "-")
-		for _, interaction := range InteractionList {
-			if args[0] == interaction.Prefix {
-				go interaction.Execute(payload, config)
-				break
+		// each action goes to its own handler, handlers reading the first action of the payload
+		for _, action := range payload.ActionCallback.BlockActions {
+			interaction, found := findInteraction(action.ActionID)
+			if !found || interaction.Execute == nil {
+				log.Printf("No handler for action %q\n", action.ActionID)
+				continue
 			}
+			single := payload
+			single.ActionCallback.BlockActions = []*slack.BlockAction{action}
+			go interaction.Execute(single, config)
 		}
-		return
+
+	case slack.InteractionTypeViewSubmission:
+		// modals use their callback id the same way buttons use their action id
+		interaction, found := findInteraction(payload.View.CallbackID)
+		if !found {
+			log.Printf("No handler for view %q\n", payload.View.CallbackID)
+			break
+		}
+		// submissions needing an answer are handled synchronously
+		if interaction.Submit != nil {
+			if response := interaction.Submit(payload, config); response != nil {
+				return response
+			}
+		} else if interaction.Execute != nil {
+			go interaction.Execute(payload, config)
+		}
+
+	case slack.InteractionTypeViewClosed:
+		// only sent for modals opened with notify_on_close
+		if interaction, found := findInteraction(payload.View.CallbackID); found && interaction.Closed != nil {
+			go interaction.Closed(payload, config)
+		}
+
+	case slack.InteractionTypeShortcut, slack.InteractionTypeMessageAction:
+		// shortcuts are configured with their callback id, like "trackshortcut-global"
+		interaction, found := findInteraction(payload.CallbackID)
+		if !found || interaction.Execute == nil {
+			log.Printf("No handler for shortcut %q\n", payload.CallbackID)
+			break
+		}
+		go interaction.Execute(payload, config)
+
+	case slack.InteractionTypeBlockSuggestion:
+		// slack waits for the options, so there is always an answer, empty when nothing matches
+		if interaction, found := findInteraction(payload.ActionID); found && interaction.Options != nil {
+			if response := interaction.Options(payload, config); response != nil {
+				return response
+			}
+		}
+		return &slack.OptionsResponse{}
+
 	default:
-		// nothing yet
-		return
+		log.Printf("Unhandled interaction type %q\n", payload.Type)
+	}
+	return nil
+}
+
+// findInteraction returns the interaction handling an action id or a callback id
+func findInteraction(id string) (shared.Interaction, bool) {
+	prefix, _, _ := strings.Cut(id, "-")
+	for _, interaction := range InteractionList {
+		if interaction.Prefix == prefix {
+			return interaction, true
+		}
 	}
+	return shared.Interaction{}, false
 }
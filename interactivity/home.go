@@ -0,0 +1,73 @@
+package interactivity
+
+import (
+	"flight-tracker-slack/commands"
+	"flight-tracker-slack/home"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// buttons of the Home tab: "home-untrack-<flight id>" and "home-settings-<flight id>",
+// the flight and the channel being in their signed value
+var HomeInteraction = shared.Interaction{
+	Prefix:  "home",
+	Execute: HandleHomeInteraction,
+}
+
+func HandleHomeInteraction(payload slack.InteractionCallback, config shared.Config) {
+	// flight ids may contain dashes
+	args := strings.SplitN(payload.ActionCallback.BlockActions[0].ActionID, "-", 3)
+	if len(args) < 3 {
+		log.Printf("Invalid action ID format: %s\n", payload.ActionCallback.BlockActions[0].ActionID)
+		return
+	}
+	action, err := shared.DecodeAction(payload.ActionCallback.BlockActions[0].Value, config)
+	if err != nil {
+		log.Printf("Invalid home action %q: %v\n", payload.ActionCallback.BlockActions[0].Value, err)
+		return
+	}
+
+	switch args[1] {
+	case "untrack":
+		untrackFromHome(action.FlightID, payload.User.ID, config)
+	case "settings":
+		openSettingsFromHome(action.Channel, payload, config)
+	}
+}
+
+func untrackFromHome(flightID string, userID string, config shared.Config) {
+	subs, err := shared.GetSubscriptions(shared.SubscriptionFilter{FlightID: flightID, SlackUserID: userID}, config)
+	if err != nil {
+		log.Printf("Error loading subscriptions of %s to %s: %v\n", userID, flightID, err)
+		return
+	}
+	for _, sub := range subs {
+		if err := shared.Unsubscribe(sub.ID, config); err != nil {
+			log.Printf("Error untracking subscription %s: %v\n", sub.ID, err)
+		}
+	}
+	home.Publish(userID, config)
+}
+
+func openSettingsFromHome(channel string, payload slack.InteractionCallback, config shared.Config) {
+	locale := shared.ResolveLocale("", payload.User.ID, config)
+
+	// alerts sent by dm use the settings of the user
+	scopeText := "<#" + channel + ">"
+	if shared.IsUserID(channel) {
+		scopeText = i18n.T(locale, "settings.scope_me")
+	}
+
+	prefs, err := shared.GetPreferencesOrDefault(channel, config)
+	if err != nil {
+		log.Printf("Error loading preferences of %s: %v\n", channel, err)
+		return
+	}
+	if _, err := config.SlackClient.OpenView(payload.TriggerID, commands.SettingsModal(locale, prefs, scopeText, channel)); err != nil {
+		log.Printf("Error opening settings from home: %v\n", err)
+	}
+}
@@ -0,0 +1,112 @@
+package interactivity
+
+import (
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+var SettingsInteraction = shared.Interaction{
+	Prefix:  "flightsettings",
+	Execute: HandleSettingsSubmit,
+}
+
+func HandleSettingsSubmit(payload slack.InteractionCallback, config shared.Config) {
+	// metadata is "scope_id|channel_id"
+	metadata := strings.SplitN(payload.View.PrivateMetadata, "|", 2)
+	if len(metadata) < 2 || metadata[0] == "" {
+		log.Printf("Invalid settings metadata: %q\n", payload.View.PrivateMetadata)
+		return
+	}
+	scopeID := metadata[0]
+	channelID := metadata[1]
+
+	if payload.View.State == nil {
+		log.Println("No state values found in the settings submission.")
+		return
+	}
+
+	prefs := shared.DefaultPreferences(scopeID)
+	prefs.GateAlerts = false
+	prefs.DelayAlerts = false
+	prefs.TakeoffAlerts = false
+	prefs.LandingAlerts = false
+	prefs.InFlightUpdates = false
+
+	for _, block := range payload.View.State.Values {
+		if val, ok := block["flightsettings-categories"]; ok {
+			for _, option := range val.SelectedOptions {
+				switch option.Value {
+				case shared.AlertCategoryGate:
+					prefs.GateAlerts = true
+				case shared.AlertCategoryDelay:
+					prefs.DelayAlerts = true
+				case shared.AlertCategoryTakeoff:
+					prefs.TakeoffAlerts = true
+				case shared.AlertCategoryLanding:
+					prefs.LandingAlerts = true
+				case shared.AlertCategoryInFlight:
+					prefs.InFlightUpdates = true
+				}
+			}
+		}
+		if val, ok := block["flightsettings-delaythreshold"]; ok {
+			if threshold, err := strconv.ParseInt(val.SelectedOption.Value, 10, 64); err == nil && threshold > 0 {
+				prefs.DelayThreshold = threshold
+			}
+		}
+		if val, ok := block["flightsettings-quietstart"]; ok {
+			if minutes, err := strconv.Atoi(val.SelectedOption.Value); err == nil {
+				prefs.QuietStart = minutes
+			}
+		}
+		if val, ok := block["flightsettings-quietend"]; ok {
+			if minutes, err := strconv.Atoi(val.SelectedOption.Value); err == nil {
+				prefs.QuietEnd = minutes
+			}
+		}
+		if val, ok := block["flightsettings-locale"]; ok {
+			// "auto" follows the slack language of each user
+			prefs.Locale = i18n.Normalize(val.SelectedOption.Value)
+		}
+		if val, ok := block["flightsettings-updateinterval"]; ok {
+			if interval, err := strconv.ParseInt(val.SelectedOption.Value, 10, 64); err == nil && interval > 0 {
+				prefs.UpdateInterval = interval
+			}
+		}
+	}
+
+	// quiet hours need both ends
+	if prefs.QuietStart < 0 || prefs.QuietEnd < 0 {
+		prefs.QuietStart = -1
+		prefs.QuietEnd = -1
+	}
+
+	// quiet hours are in the timezone of whoever saved the settings
+	user, err := config.SlackClient.GetUserInfo(payload.User.ID)
+	if err != nil {
+		log.Printf("Error fetching user info for %s: %v\n", payload.User.ID, err)
+	} else {
+		prefs.Timezone = user.TZ
+	}
+
+	err = shared.SavePreferences(prefs, config)
+	locale := shared.ResolveLocale(channelID, payload.User.ID, config)
+	if err != nil {
+		log.Printf("Error saving preferences for %s: %v\n", scopeID, err)
+		config.SlackClient.PostEphemeral(channelID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, err)...))
+		return
+	}
+
+	config.SlackClient.PostEphemeral(channelID, payload.User.ID, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "settings.saved"), false, false),
+			nil,
+			nil,
+		),
+	))
+}
@@ -0,0 +1,79 @@
+package interactivity
+
+import (
+	"flight-tracker-slack/commands"
+	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+
+	"github.com/slack-go/slack"
+)
+
+// the "Track a flight" shortcuts: the global one opens the tracking modal,
+// the message one offers to track the flights mentioned in a message
+var TrackShortcutInteraction shared.Interaction = shared.Interaction{
+	Prefix:  "trackshortcut",
+	Execute: HandleTrackShortcut,
+}
+
+func HandleTrackShortcut(payload slack.InteractionCallback, config shared.Config) {
+	switch payload.CallbackID {
+	case "trackshortcut-global":
+		locale := shared.ResolveLocale("", payload.User.ID, config)
+		if _, err := config.SlackClient.OpenView(payload.TriggerID, commands.TrackModal(locale, "", shared.TrackRequest{}, "", nil, "")); err != nil {
+			log.Printf("Error opening track modal: %v\n", err)
+		}
+	case "trackshortcut-message":
+		locale := shared.ResolveLocale(payload.Channel.ID, payload.User.ID, config)
+		blocks := flightButtons(locale, flights.FindFlightNumbers(payload.Message.Text), config)
+
+		// the response url works even in channels the bot isn't in
+		err := slack.PostWebhook(payload.ResponseURL, &slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Blocks:       &slack.Blocks{BlockSet: blocks},
+		})
+		if err != nil {
+			log.Printf("Error answering message shortcut: %v\n", err)
+		}
+	}
+}
+
+// flightButtons lists the flights found in a message, each with a button opening the tracking modal
+func flightButtons(locale string, numbers []string, config shared.Config) []slack.Block {
+	if len(numbers) == 0 {
+		return []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "shortcut.none"), false, false),
+				nil,
+				nil,
+			),
+		}
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "shortcut.found"), false, false),
+			nil,
+			nil,
+		),
+	}
+	for _, number := range numbers {
+		value, err := shared.EncodeAction(shared.ActionValue{Track: &shared.TrackRequest{FlightNumber: number}}, config)
+		if err != nil {
+			continue
+		}
+		// action ids must be unique within a message
+		button := slack.NewButtonBlockElement(
+			"trackopen-"+number,
+			value,
+			slack.NewTextBlockObject(slack.PlainTextType, i18n.T(locale, "track.open_flight", number), false, false),
+		)
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "*"+number+"*", false, false),
+			nil,
+			slack.NewAccessory(button),
+		))
+	}
+	return blocks
+}
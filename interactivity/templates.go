@@ -0,0 +1,66 @@
+package interactivity
+
+import (
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"flight-tracker-slack/templates"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+var TemplatesInteraction = shared.Interaction{
+	Prefix: "flighttemplates",
+	Submit: HandleTemplateSubmit,
+}
+
+// HandleTemplateSubmit validates the template before saving it, so the modal can show the error inline
+func HandleTemplateSubmit(payload slack.InteractionCallback, config shared.Config) *slack.ViewSubmissionResponse {
+	// metadata is "scope_id|alert_type|channel_id"
+	metadata := strings.SplitN(payload.View.PrivateMetadata, "|", 3)
+	if len(metadata) < 3 || !templates.IsAlertType(metadata[1]) {
+		log.Printf("Invalid template metadata: %q\n", payload.View.PrivateMetadata)
+		return nil
+	}
+	scopeID, alertType, channelID := metadata[0], metadata[1], metadata[2]
+
+	var body string
+	if payload.View.State != nil {
+		if block, ok := payload.View.State.Values["flighttemplates-body"]; ok {
+			body = block["flighttemplates-body"].Value
+		}
+	}
+
+	locale := shared.ResolveLocale(channelID, payload.User.ID, config)
+
+	if err := templates.Validate(alertType, body); err != nil {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			"flighttemplates-body": err.Error(),
+		})
+	}
+
+	err := shared.SaveAlertTemplate(shared.AlertTemplate{
+		ScopeID:   scopeID,
+		AlertType: alertType,
+		Body:      body,
+		UpdatedBy: payload.User.ID,
+		UpdatedAt: time.Now().Unix(),
+	}, config)
+	if err != nil {
+		log.Printf("Error saving %s template for %s: %v\n", alertType, scopeID, err)
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			"flighttemplates-body": i18n.T(locale, "templates.save_error", err),
+		})
+	}
+
+	go config.SlackClient.PostEphemeral(channelID, payload.User.ID, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "templates.saved", alertType), false, false),
+			nil,
+			nil,
+		),
+	))
+	return nil
+}
@@ -1,124 +1,250 @@
 package interactivity
 
 import (
-	"errors"
+	"flight-tracker-slack/commands"
 	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
-	"fmt"
 	"log"
+	"regexp"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/slack-go/slack"
 )
 
+// the tracking modal: changing the flight number, its airline or the date reloads its legs, submitting tracks the flight
 var TrackInteraction shared.Interaction = shared.Interaction{
-	Prefix:  "trackflightformsubmit",
-	Execute: HandleTrackFlightFormSubmit,
+	Prefix:  "trackflight",
+	Execute: HandleTrackChanged,
+	Submit:  HandleTrackSubmit,
+	Options: HandleTrackOptions,
 }
 
-func HandleTrackFlightFormSubmit(payload slack.InteractionCallback, config shared.Config) {
-	for _, action := range payload.ActionCallback.BlockActions {
-		if action.ActionID == "trackflightformsubmit-button" {
-			log.Printf("Received track flight form submit interaction with value: %s\n", action.Value)
-			flightNum := action.Value
-			_ = flightNum // use this to avoid unused variable error for now
-
-			var selectedDate, selectedTime string
-			if payload.BlockActionState == nil || payload.BlockActionState.Values == nil {
-				log.Println("No state values found in the payload.")
-				return
-			}
-			for _, block := range payload.BlockActionState.Values {
-				if val, ok := block["trackflightformsubmit-departuredate"]; ok {
-					selectedDate = val.SelectedDate
-				}
-				if val, ok := block["trackflightformsubmit-departuretime"]; ok {
-					selectedTime = val.SelectedTime
-				}
-			}
-			if selectedDate == "" || selectedTime == "" {
-				config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
-					shared.NewErrorBlocks(errors.New("Please select both a departure date and time before submitting the form."))...,
-				))
-				return
-			}
-
-			// find the departure airport tz
-
-			flightData, err := flights.GetFlightInfo(flightNum)
-			if err != nil {
-				log.Printf("Error fetching flight info for %s: %v\n", flightNum, err)
-				config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
-					shared.NewErrorBlocks(fmt.Errorf("Could not fetch flight information for %s. Please check the flight number and try again.", flightNum))...,
-				))
-				return
-			}
-			firstFlight := flightData.GetFirstFlight()
-			if firstFlight == nil {
-				log.Printf("No flight data found for flight number %s\n", flightNum)
-				config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
-					shared.NewErrorBlocks(fmt.Errorf("No flight information found for flight number %s. Please check the flight number and try again.", flightNum))...,
-				))
-				return
-			}
-			if firstFlight.Origin.Iata == "" {
-				log.Printf("No active flight found for flight number %s\n", flightNum)
-				config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
-					shared.NewErrorBlocks(fmt.Errorf("No active flight found for flight number %s. Please check the flight number and try again.", flightNum))...,
-				))
-				return
-			}
-
-			// register the flight for tracking
-			var departureUnix int64
-			var departureDateTime time.Time
-			// remove the ":" at the start of the timezone string
-			correctedTimezone := strings.TrimPrefix(firstFlight.Origin.TZ, ":")
-			loc, err := time.LoadLocation(correctedTimezone)
-			if err != nil {
-				log.Printf("Error loading timezone %q: %v\n", firstFlight.Origin.TZ, err)
-				loc = time.UTC
-			}
-			departureDateTime, err = time.ParseInLocation("2006-01-02", selectedDate, loc)
-			if err != nil {
-				log.Printf("Error parsing departure date: %v\n", err)
-				return
-			}
-			departure_time, err := time.Parse("15:04", selectedTime)
-			if err != nil {
-				log.Printf("Error parsing departure time: %v\n", err)
-				return
-			}
-			departureDateTime = time.Date(departureDateTime.Year(), departureDateTime.Month(), departureDateTime.Day(), departure_time.Hour(), departure_time.Minute(), 0, 0, loc)
-			departureUnix = departureDateTime.Unix()
-
-			var flight shared.Flight = shared.Flight{
-				ID:           uuid.New().String(),
-				FlightNumber: flightNum,
-				Departure:    departureUnix,
-				SlackChannel: payload.Channel.ID,
-				SlackUserID:  payload.User.ID,
-			}
-			err = shared.RegisterTrackedFlight(flight, config)
-			if err != nil {
-				log.Printf("Error registering tracked flight: %v\n", err)
-				config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
-					shared.NewErrorBlocks(err)...,
-				))
-			}
-
-			// send a message to the channel confirming the tracking
-			config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
-				slack.NewSectionBlock(
-					slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Flight added for tracking in channel <#%s>! :airplane:", payload.Channel.ID), false, false),
-					nil,
-					nil,
-				),
-			))
+// a flight number typed without its airline code, completed with the airline chosen in the modal
+var flightDigitsPattern = regexp.MustCompile(`^\d{1,4}[A-Z]?$`)
 
+// buttons opening the tracking modal, their value being a json encoded track request
+var TrackOpenInteraction shared.Interaction = shared.Interaction{
+	Prefix:  "trackopen",
+	Execute: HandleTrackOpen,
+}
+
+func HandleTrackOpen(payload slack.InteractionCallback, config shared.Config) {
+	locale := shared.ResolveLocale(payload.Channel.ID, payload.User.ID, config)
+
+	value, err := shared.DecodeAction(payload.ActionCallback.BlockActions[0].Value, config)
+	if err != nil || value.Track == nil {
+		log.Printf("Invalid track request %q: %v\n", payload.ActionCallback.BlockActions[0].Value, err)
+		return
+	}
+	request := *value.Track
+
+	status := ""
+	if request.FlightNumber != "" {
+		status = i18n.T(locale, "track.loading", request.FlightNumber)
+	}
+	view, err := config.SlackClient.OpenView(payload.TriggerID, commands.TrackModal(locale, payload.Channel.ID, request, "", nil, status))
+	if err != nil {
+		log.Printf("Error opening track modal: %v\n", err)
+		return
+	}
+	if request.FlightNumber == "" {
+		return
+	}
+
+	modal := commands.LoadTrackModal(locale, payload.Channel.ID, request)
+	if _, err := config.SlackClient.UpdateView(modal, "", view.Hash, view.ID); err != nil {
+		log.Printf("Error updating track modal: %v\n", err)
+	}
+}
+
+// HandleTrackChanged reloads the legs of the modal when its flight number, its date or its departure airport changes
+func HandleTrackChanged(payload slack.InteractionCallback, config shared.Config) {
+	if len(payload.ActionCallback.BlockActions) == 0 {
+		return
+	}
+	action := payload.ActionCallback.BlockActions[0]
+	channelID, _, _ := strings.Cut(payload.View.PrivateMetadata, "|")
+	locale := shared.ResolveLocale(channelID, payload.User.ID, config)
+
+	request := trackRequestFromState(payload.View.State)
+	var number, airline string
+	if state := payload.View.State; state != nil {
+		number = state.Values["trackflight-number"]["trackflight-number"].Value
+		airline = state.Values["trackflight-airline"]["trackflight-airline"].SelectedOption.Value
+		if conversation := state.Values["trackflight-channel"]["trackflight-channel"].SelectedConversation; conversation != "" {
+			channelID = conversation
+		}
+	}
+
+	switch action.ActionID {
+	case "trackflight-number":
+		request.FlightNumber = enteredFlightNumber(action.Value, airline)
+	case "trackflight-airline", "trackflight-date", "trackflight-origin":
+		switch action.ActionID {
+		case "trackflight-airline":
+			airline = action.SelectedOption.Value
+		case "trackflight-date":
+			request.Date = action.SelectedDate
+		case "trackflight-origin":
+			request.Origin = action.SelectedOption.Value
+		}
+		// without a flight yet, there is nothing to reload
+		request.FlightNumber = enteredFlightNumber(number, airline)
+		if !flights.FlightNumPattern.MatchString(request.FlightNumber) {
 			return
 		}
+	default:
+		return
+	}
+
+	if !flights.FlightNumPattern.MatchString(request.FlightNumber) {
+		modal := commands.TrackModal(locale, channelID, request, "", nil, i18n.T(locale, "flight.invalid")+"\n"+i18n.T(locale, "flight.invalid_hint"))
+		if _, err := config.SlackClient.UpdateView(modal, "", payload.View.Hash, payload.View.ID); err != nil {
+			log.Printf("Error updating track modal: %v\n", err)
+		}
+		return
+	}
+
+	view, err := config.SlackClient.UpdateView(commands.TrackModal(locale, channelID, request, "", nil, i18n.T(locale, "track.loading", request.FlightNumber)), "", payload.View.Hash, payload.View.ID)
+	if err != nil {
+		log.Printf("Error updating track modal: %v\n", err)
+		return
+	}
+	if _, err := config.SlackClient.UpdateView(commands.LoadTrackModal(locale, channelID, request), "", view.Hash, view.ID); err != nil {
+		log.Printf("Error updating track modal: %v\n", err)
+	}
+}
+
+// HandleTrackSubmit validates the tracking modal, showing errors next to the fields, and tracks the flight
+func HandleTrackSubmit(payload slack.InteractionCallback, config shared.Config) *slack.ViewSubmissionResponse {
+	// metadata is "channel_id|flight_number|leg", the flight number whose legs are shown
+	// and the leg when there was only one
+	metadata := strings.SplitN(payload.View.PrivateMetadata, "|", 3)
+	for len(metadata) < 3 {
+		metadata = append(metadata, "")
+	}
+	channelID, loadedNumber, loadedLeg := metadata[0], metadata[1], metadata[2]
+	locale := shared.ResolveLocale(channelID, payload.User.ID, config)
+
+	state := payload.View.State
+	if state == nil {
+		log.Println("No state values found in the track submission.")
+		return nil
+	}
+	request := trackRequestFromState(state)
+	request.FlightNumber = enteredFlightNumber(state.Values["trackflight-number"]["trackflight-number"].Value, state.Values["trackflight-airline"]["trackflight-airline"].SelectedOption.Value)
+
+	errs := make(map[string]string)
+	switch {
+	case !flights.FlightNumPattern.MatchString(request.FlightNumber):
+		errs["trackflight-number"] = i18n.T(locale, "flight.invalid")
+	case request.FlightNumber != loadedNumber:
+		errs["trackflight-number"] = i18n.T(locale, "track.press_enter")
+	}
+
+	legValue := state.Values["trackflight-leg"]["trackflight-leg"].SelectedOption.Value
+	if legValue == "" {
+		legValue = loadedLeg
+	}
+	leg, err := flights.ParseLeg(legValue)
+	if err != nil && len(errs) == 0 {
+		// without legs, the flight number wasn't found
+		if _, shown := state.Values["trackflight-leg"]; shown {
+			errs["trackflight-leg"] = i18n.T(locale, "track.leg_missing")
+		} else {
+			errs["trackflight-number"] = i18n.T(locale, "track.not_found")
+		}
+	}
+
+	var departure time.Time
+	if date, err := time.Parse(time.DateOnly, request.Date); err != nil {
+		errs["trackflight-date"] = i18n.T(locale, "track.date_missing")
+	} else if len(errs) == 0 {
+		departure = leg.On(date)
+		// a flight of the previous day may not have arrived yet
+		if time.Since(departure) > 24*time.Hour {
+			errs["trackflight-date"] = i18n.T(locale, "track.date_past")
+		}
+	}
+
+	if len(errs) > 0 {
+		return slack.NewErrorsViewSubmissionResponse(errs)
+	}
+
+	target := state.Values["trackflight-channel"]["trackflight-channel"].SelectedConversation
+	if target == "" {
+		target = channelID
+	}
+	go commands.TrackFlight(locale, request, departure, target, payload.User.ID, "", config)
+	return nil
+}
+
+// HandleTrackOptions searches the airlines and the airports of the modal by name or code
+func HandleTrackOptions(payload slack.InteractionCallback, config shared.Config) *slack.OptionsResponse {
+	locale := shared.ResolveLocale("", payload.User.ID, config)
+	switch payload.ActionID {
+	case "trackflight-airline":
+		return airlineOptions(locale, payload.Value)
+	case "trackflight-origin":
+		return airportOptions(locale, payload.Value)
+	}
+	return nil
+}
+
+// airlineOptions searches the airlines database, the option values being the iata codes
+func airlineOptions(locale string, query string) *slack.OptionsResponse {
+
+	airlines, err := flights.SearchAirlines(query, maxOptions)
+	if err != nil {
+		log.Printf("Error searching airlines: %v\n", err)
+		return nil
+	}
+	options := make([]*slack.OptionBlockObject, 0, len(airlines))
+	for _, airline := range airlines {
+		text := i18n.T(locale, "track.airline_option", airline.Name, airline.IATA)
+		options = append(options, slack.NewOptionBlockObject(airline.IATA, slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil))
+	}
+	return &slack.OptionsResponse{Options: options}
+}
+
+// airportOptions searches the airports, the option values being the iata codes
+func airportOptions(locale string, query string) *slack.OptionsResponse {
+	airports, err := flights.SearchAirports(query, maxOptions)
+	if err != nil {
+		log.Printf("Error searching airports: %v\n", err)
+		return nil
+	}
+	options := make([]*slack.OptionBlockObject, 0, len(airports))
+	for _, airport := range airports {
+		options = append(options, commands.AirportOption(locale, airport))
+	}
+	return &slack.OptionsResponse{Options: options}
+}
+
+// enteredFlightNumber returns the flight number typed in the modal, completed with the airline code if it's only a number
+func enteredFlightNumber(number string, airline string) string {
+	number = strings.ToUpper(strings.TrimSpace(number))
+	if airline != "" && flightDigitsPattern.MatchString(number) {
+		return airline + number
+	}
+	return number
+}
+
+// trackRequestFromState reads the optional fields of the tracking modal
+func trackRequestFromState(state *slack.ViewState) shared.TrackRequest {
+	var request shared.TrackRequest
+	if state == nil {
+		return request
+	}
+	request.Date = state.Values["trackflight-date"]["trackflight-date"].SelectedDate
+	request.Origin = state.Values["trackflight-origin"]["trackflight-origin"].SelectedOption.Value
+	request.TravelerID = state.Values["trackflight-traveler"]["trackflight-traveler"].SelectedUser
+	for _, option := range state.Values["trackflight-options"]["trackflight-options"].SelectedOptions {
+		if option.Value == "dm" {
+			request.DM = true
+		}
 	}
+	return request
 }
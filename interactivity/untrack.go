@@ -1,10 +1,12 @@
 package interactivity
 
 import (
+	"errors"
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/shared"
-	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/slack-go/slack"
 )
@@ -16,44 +18,118 @@ var UntrackInteraction = shared.Interaction{
 
 func HandleUntrackInteraction(payload slack.InteractionCallback, config shared.Config) {
 	log.Printf("Handling untrack interaction for user %s in channel %s\n", payload.User.ID, payload.Channel.ID)
-	args := strings.Split(payload.ActionCallback.BlockActions[0].ActionID, "-")
-	if len(args) < 3 {
-		log.Printf("Invalid action ID format: %s\n", payload.ActionCallback.BlockActions[0].ActionID)
-		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(fmt.Errorf("Something went wrong while trying to untrack the flight. Please try again."))...))
+	locale := shared.ResolveLocale(payload.Channel.ID, payload.User.ID, config)
+
+	// the value names the exact subscription, signed by UntrackButton
+	action, err := shared.DecodeAction(payload.ActionCallback.BlockActions[0].Value, config)
+	if err != nil || action.SubscriptionID == "" {
+		log.Printf("Invalid untrack action %q: %v\n", payload.ActionCallback.BlockActions[0].Value, err)
+		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, errors.New(i18n.T(locale, "untrack.error")))...))
 		return
 	}
-	channel := args[2]
-	flightNumber := args[1]
-	user := payload.User.ID
 
-	filter := shared.FlightFilter{
-		FlightNumber: flightNumber,
-		SlackChannel: channel,
-		SlackUserID:  user,
+	filter := shared.SubscriptionFilter{
+		ID:          action.SubscriptionID,
+		FlightID:    action.FlightID,
+		SlackUserID: payload.User.ID,
 	}
 
-	flight, err := shared.GetFlights(filter, config)
+	subs, err := shared.GetSubscriptions(filter, config)
 	if err != nil {
-		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(err)...))
+		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, err)...))
 		return
 	}
-	if len(flight) == 0 {
-		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(fmt.Errorf("No tracked flight found for flight number %s in channel <#%s>.", flightNumber, channel))...))
+	if len(subs) == 0 {
+		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, errors.New(i18n.T(locale, "untrack.not_found")))...))
 		return
 	}
 
-	err = shared.UntrackFlight(flight[0].ID, config)
+	err = shared.Unsubscribe(subs[0].ID, config)
+	if err != nil {
+		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, err)...))
+		return
+	}
+
+	config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", i18n.T(locale, "untrack.success", subs[0].FlightNumber, subs[0].SlackChannel), false, false),
+			nil,
+			nil,
+		),
+	))
+
+}
+
+// the searchable select of /untrack-flight, offering the flights tracked by the user
+var UntrackSelectInteraction = shared.Interaction{
+	Prefix:  "untrackselect",
+	Execute: HandleUntrackSelect,
+	Options: UntrackOptions,
+}
+
+// slack shows at most 100 options
+const maxOptions = 100
+
+// HandleUntrackSelect untracks the flight chosen in the select, its value being the subscription id
+func HandleUntrackSelect(payload slack.InteractionCallback, config shared.Config) {
+	locale := shared.ResolveLocale(payload.Channel.ID, payload.User.ID, config)
+	subscriptionID := payload.ActionCallback.BlockActions[0].SelectedOption.Value
+
+	// only the flights of the user can be untracked this way
+	subs, err := shared.GetSubscriptions(shared.SubscriptionFilter{ID: subscriptionID, SlackUserID: payload.User.ID}, config)
+	if err == nil && len(subs) == 0 {
+		err = errors.New(i18n.T(locale, "untrack.not_found"))
+	}
+	if err == nil {
+		err = shared.Unsubscribe(subs[0].ID, config)
+	}
 	if err != nil {
-		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(err)...))
+		config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(shared.NewErrorBlocks(locale, err)...))
 		return
 	}
 
 	config.SlackClient.PostEphemeral(payload.Channel.ID, payload.User.ID, slack.MsgOptionBlocks(
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Successfully untracked flight *%s* in channel <#%s>.", flightNumber, channel), false, false),
+			slack.NewTextBlockObject("mrkdwn", i18n.T(locale, "untrack.success", subs[0].FlightNumber, subs[0].SlackChannel), false, false),
 			nil,
 			nil,
 		),
 	))
+}
+
+// UntrackOptions lists the flights of the user whose number starts with what they typed
+func UntrackOptions(payload slack.InteractionCallback, config shared.Config) *slack.OptionsResponse {
+	locale := shared.ResolveLocale(payload.Channel.ID, payload.User.ID, config)
+	subs, err := shared.GetSubscriptions(shared.SubscriptionFilter{
+		SlackUserID:        payload.User.ID,
+		FlightNumberPrefix: strings.ToUpper(strings.TrimSpace(payload.Value)),
+	}, config)
+	if err != nil {
+		log.Printf("Error searching flights of %s: %v\n", payload.User.ID, err)
+		return nil
+	}
+
+	// options are plain text, so channels are shown by name
+	names := make(map[string]string)
+	options := make([]*slack.OptionBlockObject, 0, len(subs))
+	for _, sub := range subs {
+		if len(options) == maxOptions {
+			break
+		}
+		name, found := names[sub.SlackChannel]
+		if !found {
+			name = i18n.T(locale, "untrack.select_dm")
+			if !sub.IsDM() {
+				name = "#" + sub.SlackChannel
+				if info, err := config.SlackClient.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: sub.SlackChannel}); err == nil {
+					name = "#" + info.Name
+				}
+			}
+			names[sub.SlackChannel] = name
+		}
 
+		text := i18n.T(locale, "untrack.select_option", sub.FlightNumber, i18n.FormatDate(locale, time.Unix(sub.Departure, 0)), name)
+		options = append(options, slack.NewOptionBlockObject(sub.ID, slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil))
+	}
+	return &slack.OptionsResponse{Options: options}
 }
@@ -1,18 +1,20 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"errors"
+	"flight-tracker-slack/events"
 	"flight-tracker-slack/flights"
+	"flight-tracker-slack/home"
+	"flight-tracker-slack/i18n"
 	"flight-tracker-slack/maps"
 	"flight-tracker-slack/shared"
+	"flight-tracker-slack/templates"
+	"flight-tracker-slack/webhooks"
 	"fmt"
 	"image"
-	"image/png"
 	"log"
-	"strings"
 	"sync"
 	"time"
 
@@ -21,15 +23,33 @@ import (
 
 type LogicLoop struct {
 	Config        shared.Config
+	Bus           *events.Bus
 	flightCancels map[string]context.CancelFunc
 	mu            sync.Mutex
+
+	// last generated map, shared by the subscribers of a flight
+	mapMu         sync.Mutex
+	lastMapDetail *flights.FlightDetail
+	lastMap       *image.RGBA
+
+	// wakes the outbox sender up when a message is queued
+	outboxWake chan struct{}
+	queue      *sendQueue
 }
 
 func NewLogicLoop(cfg shared.Config) *LogicLoop {
-	return &LogicLoop{
+	b := &LogicLoop{
 		Config:        cfg,
+		Bus:           events.NewBus(),
 		flightCancels: make(map[string]context.CancelFunc),
+		outboxWake:    make(chan struct{}, 1),
+		queue:         &sendQueue{lastSent: make(map[string]time.Time)},
 	}
+
+	b.Bus.Subscribe("log", events.LogSink)
+	b.Bus.Subscribe("slack", b.sendAlertEvent)
+	b.Bus.Subscribe("webhooks", webhooks.Sink(cfg))
+	return b
 }
 
 func (b *LogicLoop) Run() {
@@ -45,6 +65,9 @@ func (b *LogicLoop) Run() {
 
 	log.Printf("Loaded %d flights from database\n", len(flights))
 
+	go b.runOutbox()
+	go webhooks.RunRetries(b.Config)
+
 	for _, f := range flights {
 		log.Printf("Tracking flight %s with departure at %s (or in %s)\n", f.ID, time.Unix(f.Departure, 0).Format(time.Kitchen), shared.FormatDuration(time.Until(time.Unix(f.Departure, 0))))
 		b.addFlight(f)
@@ -55,15 +78,10 @@ func (b *LogicLoop) Run() {
 
 	for range ticker.C {
 		b.syncFlights()
+		b.flushHeldAlerts()
 	}
 }
 
-func WasAlertSent(flightID, alertType string, config shared.Config) bool {
-	row := config.UserDB.QueryRow("SELECT 1 FROM alerts_sent WHERE flight_id = ? AND alert_type = ?", flightID, alertType)
-	var exists int
-	return row.Scan(&exists) == nil
-}
-
 func (b *LogicLoop) syncFlights() {
 	flights, err := shared.GetFlights(shared.FlightFilter{}, b.Config)
 	if err != nil {
@@ -118,253 +136,256 @@ func (b *LogicLoop) trackFlight(ctx context.Context, f shared.Flight) {
 				continue
 			}
 
+			b.inboundAircraft(prev, &curr, currData)
+
+			subs, err := shared.GetSubscriptions(shared.SubscriptionFilter{FlightID: f.ID}, b.Config)
+			if err != nil {
+				log.Println("Error loading subscriptions for", f.ID, ":", err)
+			}
+
 			b.detectChanges(f, prev, &curr, currData)
 
 			shared.SaveFlightState(curr, b.Config)
 
+			// connections are checked against the saved state
+			b.detectConnections(f, prev, &curr, currData)
+
+			// the home tabs of whoever tracks the flight follow its state
+			if home.Changed(prev, &curr) {
+				home.PublishSubscribers(subs, b.Config)
+			}
+
+			// once arrived at gate, remove it from tracking & db, its trip legs keeping its arrival
+			if trackingDone(&curr, currData, time.Now()) {
+				log.Printf("Flight %s has arrived at gate, stopping tracking\n", f.ID)
+				b.removeFlight(f.ID)
+			}
+
 		}
 	}
 }
 
-func (b *LogicLoop) detectChanges(f shared.Flight, prev *shared.FlightState, curr *shared.FlightState, currData *flights.FlightDetail) {
+// how long a flight is still polled after its arrival, waiting for its baggage belt,
+// when its destination sends one
+const baggageClaimWait = 30 * time.Minute
 
-	destinationTimezone := strings.TrimPrefix(currData.Destination.TZ, ":")
-	destLoc, err := time.LoadLocation(destinationTimezone)
-	if err != nil {
-		log.Printf("Error loading destination timezone %q: %v\n", currData.Destination.TZ, err)
-		destLoc = time.UTC
-	}
-	departureTimezone := strings.TrimPrefix(currData.Origin.TZ, ":")
-	depLoc, err := time.LoadLocation(departureTimezone)
+func (b *LogicLoop) detectChanges(f shared.Flight, prev *shared.FlightState, curr *shared.FlightState, currData *flights.FlightDetail) {
+	subs, err := shared.GetSubscriptions(shared.SubscriptionFilter{FlightID: f.ID}, b.Config)
 	if err != nil {
-		log.Printf("Error loading departure timezone %q: %v\n", currData.Origin.TZ, err)
-		depLoc = time.UTC
+		log.Println("Error loading subscriptions for", f.ID, ":", err)
+		return
 	}
 
 	if prev == nil {
 		log.Printf("No previous state for flight %s, skipping change detection\n", f.ID)
-		curr.LastAnnouncedDepEstimated = curr.DepEstimated
-		curr.LastAnnouncedArrEstimated = curr.ArrEstimated
+		for _, sub := range subs {
+			sub.LastAnnouncedDepEstimated = curr.DepEstimated
+			sub.LastAnnouncedArrEstimated = curr.ArrEstimated
+			shared.SaveAnnouncedEstimates(sub, b.Config)
+		}
 		return
 	}
 
-	// copy over the last announced times from previous state
-	curr.LastAnnouncedDepEstimated = prev.LastAnnouncedDepEstimated
-	curr.LastAnnouncedArrEstimated = prev.LastAnnouncedArrEstimated
-
-	// check if dep gate was announced
-	if curr.OriginGate != "" && WasAlertSent(f.ID, "departure_gate_announced", b.Config) == false {
-		depTime := time.Unix(curr.DepEstimated, 0).In(depLoc).Format(time.Kitchen)
-		b.sendAlert(f, "departure_gate_announced", slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*:seat: Gate announced!* :seat:\nGate *%s*\nEstimated departure time: %s ", curr.OriginGate, depTime), false, false),
-			nil,
-			nil,
-		), nil)
-	}
+	now := time.Now()
 
-	// check if the flight departed from gate
-
-	if curr.DepActual != 0 && WasAlertSent(f.ID, "flight_departed_from_gate", b.Config) == false {
-		depTime := time.Unix(curr.DepActual, 0).In(depLoc).Format(time.Kitchen)
-		depEstimated := time.Unix(curr.DepEstimated, 0).In(depLoc).Format(time.Kitchen)
-		gateMsg := ""
-		if curr.OriginGate != "" {
-			gateMsg = fmt.Sprintf("gate %s", curr.OriginGate)
-		} else {
-			gateMsg = "the gate"
+	// alerts of each subscriber, using their own preferences
+	for _, sub := range subs {
+		prefs := shared.ResolvePreferences(sub, b.Config)
+		alerts, updated := events.DetectAlerts(f, sub, prefs, prev, curr, now, b.alertSent)
+		for _, alert := range alerts {
+			alert.Detail = currData
+			b.Bus.Publish(alert)
 		}
 
-		taxiMsg := ""
-		estimatedTaxiTime := curr.TakeOffEstimated - curr.DepEstimated
-		if estimatedTaxiTime > 0 {
-			taxiMsg = fmt.Sprintf("Estimated taxi time: %s", shared.FormatDuration(time.Duration(estimatedTaxiTime)*time.Second))
+		if updated.LastAnnouncedDepEstimated != sub.LastAnnouncedDepEstimated || updated.LastAnnouncedArrEstimated != sub.LastAnnouncedArrEstimated {
+			shared.SaveAnnouncedEstimates(updated, b.Config)
 		}
-		b.sendAlert(f, "flight_departed_from_gate", slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*:airplane: Flight departed from %s! :airplane:*\nDeparture time: ~%s~ %s \n %s", gateMsg, depEstimated, depTime, taxiMsg), false, false),
-			nil,
-			nil,
-		), nil)
 	}
 
-	// check if the flight took off
-
-	if curr.TakeOffActual != 0 && WasAlertSent(f.ID, "flight_takeoff", b.Config) == false {
-		takeOffTime := time.Unix(curr.TakeOffActual, 0).In(depLoc).Format(time.Kitchen)
-		takeOffEstimated := time.Unix(curr.TakeOffEstimated, 0).In(depLoc).Format(time.Kitchen)
-		flightEstimatedDuration := curr.ArrEstimated - curr.DepEstimated
-		b.sendAlert(f, "flight_takeoff", slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":airplane_departure: *Flight took off!* :airplane_departure:\nTakeoff time: ~%s~ %s \n Estimated flight duration: %s", takeOffEstimated, takeOffTime, shared.FormatDuration(time.Duration(flightEstimatedDuration)*time.Second)), false, false),
-			nil,
-			nil,
-		), nil)
+	// flight-wide events, once whatever the number of subscribers
+	channels := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		channels = append(channels, sub.SlackChannel)
+	}
+	for _, event := range events.DetectFlight(f, prev, curr, now) {
+		event.Channels = channels
+		event.Detail = currData
+		b.Bus.Publish(event)
 	}
+}
 
-	// check if flight landed
+// trackingDone tells whether a flight arrived at gate and can stop being tracked,
+// unless the destination sends baggage belts and this one may still be announced
+func trackingDone(curr *shared.FlightState, currData *flights.FlightDetail, now time.Time) bool {
+	waitBaggage := currData.Destination.Baggage != nil && curr.DestBaggage == "" && now.Sub(time.Unix(curr.ArrActual, 0)) <= baggageClaimWait
+	return curr.ArrActual != 0 && !waitBaggage
+}
 
-	if curr.LandingActual != 0 && WasAlertSent(f.ID, "flight_landed", b.Config) == false {
-		// if gate is available, include it in the message
-		var gateMsg string
-		if curr.DestGate != "" {
-			gateMsg = fmt.Sprintf("\n Taxiing to gate %s", curr.DestGate)
-		} else {
-			gateMsg = ""
-		}
-		arrTime := time.Unix(curr.LandingActual, 0).In(destLoc).Format(time.Kitchen)
-		arrEstimated := time.Unix(curr.LandingEstimated, 0).In(destLoc).Format(time.Kitchen)
-		b.sendAlert(f, "flight_landed", slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":airplane_arriving: *Flight landed!* :airplane_arriving:\nLanding time: ~%s~ %s%s", arrEstimated, arrTime, gateMsg), false, false),
-			nil,
-			nil,
-		), nil)
-		log.Printf("Flight %s has landed, taxiing to gate\n", f.ID)
+// detectConnections checks the connections of the trips a flight is part of, for both its inbound and outbound sides
+func (b *LogicLoop) detectConnections(f shared.Flight, prev *shared.FlightState, curr *shared.FlightState, currData *flights.FlightDetail) {
+	legs, err := shared.GetTripLegs(shared.TripLegFilter{FlightID: f.ID}, b.Config)
+	if err != nil {
+		log.Println("Error loading trips of", f.ID, ":", err)
+		return
 	}
 
-	// check if flight arrived at gate
-	// if it did, remove it from tracking & db
-	if curr.ArrActual != 0 && WasAlertSent(f.ID, "flight_arrived_at_gate", b.Config) == false {
-		var gateMsg string
-		if curr.DestGate != "" {
-			gateMsg = fmt.Sprintf(" at gate %s", curr.DestGate)
-		} else {
-			gateMsg = ""
+	now := time.Now()
+	for _, leg := range legs {
+		tripLegs, err := shared.GetTripLegs(shared.TripLegFilter{TripID: leg.TripID}, b.Config)
+		if err != nil {
+			log.Println("Error loading legs of trip", leg.TripID, ":", err)
+			continue
 		}
-		arrTime := time.Unix(curr.ArrActual, 0).In(destLoc).Format(time.Kitchen)
-		arrEstimated := time.Unix(curr.ArrEstimated, 0).In(destLoc).Format(time.Kitchen)
-		b.sendAlert(f, "flight_arrived_at_gate", slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":airplane: *Flight arrived%s* :airplane:\nArrival time: ~%s~ %s", gateMsg, arrEstimated, arrTime), false, false),
-			nil,
-			nil,
-		), nil)
-		log.Printf("Flight %s has arrived at gate, stopping tracking\n", f.ID)
-		b.removeFlight(f.ID)
-		return
-	}
 
-	// regular updates during the flight (1 every 2 hours)
-	if curr.DepActual != 0 && curr.ArrActual == 0 {
-		hoursSinceDeparture := int(time.Since(time.Unix(curr.DepActual, 0)).Hours())
-		window := hoursSinceDeparture / 2
+		// the connections as they were before this poll, for the flight-wide events
+		prevConnections := shared.Connections(tripLegs, func(flightID string) *shared.FlightState {
+			if flightID == f.ID {
+				return prev
+			}
+			return b.flightState(flightID)
+		})
 
-		if window > 0 {
-			alertID := fmt.Sprintf("in_flight_update_%d", window)
-			image, err := maps.GenerateMapFromFlightDetail(b.Config.TileStore, *currData)
-			if err != nil {
-				log.Printf("Error generating map for flight %s: %v", f.ID, err)
+		for i, c := range shared.Connections(tripLegs, b.flightState) {
+			if c.Inbound.Subscription.FlightID != f.ID && c.Outbound.Subscription.FlightID != f.ID {
+				continue
+			}
+
+			// the alert goes to the subscriber of the outbound flight, once whichever side noticed it
+			sub := c.Outbound.Subscription
+			outbound := shared.Flight{
+				ID:           sub.FlightID,
+				FlightNumber: sub.FlightNumber,
+				SlackChannel: sub.SlackChannel,
+				SlackUserID:  sub.SlackUserID,
+				Departure:    sub.Departure,
+			}
+			state := b.flightState(sub.FlightID)
+			if sub.FlightID == f.ID {
+				state = curr
+			}
+
+			prefs := shared.ResolvePreferences(sub, b.Config)
+			for _, alert := range events.DetectConnection(outbound, sub, c, prefs, state, now, b.alertSent) {
+				alert.Detail = currData
+				b.Bus.Publish(alert)
+			}
+
+			// without a previous state, every connection would look new
+			if prev == nil {
+				continue
+			}
+			for _, event := range events.DetectFlightConnection(outbound, prevConnections[i], c, state, now) {
+				event.Channels = []string{sub.SlackChannel}
+				event.Detail = currData
+				b.Bus.Publish(event)
 			}
-			progressBar := shared.GenerateProgressBar(10, 100*float64(time.Since(time.Unix(curr.DepActual, 0)).Seconds())/float64(curr.ArrEstimated-curr.DepActual))
-			timeLeft := shared.FormatDuration(time.Until(time.Unix(curr.ArrEstimated, 0)))
-			b.sendAlert(f, alertID, slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, ":airplane: *Still flying!* :airplane:\n "+progressBar+"\n("+timeLeft+" left)", false, false),
-				nil,
-				nil,
-			), image)
 		}
 	}
+}
 
-	// check if departure_time is updated (by at least 15 minutes)
-	if depBaseline := lastAnnounced(prev.LastAnnouncedDepEstimated, prev.DepEstimated); curr.DepEstimated != 0 && absDuration(curr.DepEstimated-depBaseline) >= estimateChangeThreshold {
-		prevTime := time.Unix(depBaseline, 0).In(depLoc).Format(time.Kitchen)
-		currTime := time.Unix(curr.DepEstimated, 0).In(depLoc).Format(time.Kitchen)
-		b.sendAlert(f, fmt.Sprintf("departure_time_change_%d", curr.DepEstimated), slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":rotating_light: *Departure time updated!* :rotating_light:\nPrevious: %s\nNew: %s", prevTime, currTime), false, false),
-			nil,
-			nil,
-		), nil)
-		curr.LastAnnouncedDepEstimated = curr.DepEstimated
+// flightState returns the last saved state of a flight, nil if it has none
+func (b *LogicLoop) flightState(flightID string) *shared.FlightState {
+	state, err := shared.GetFlightState(flightID, b.Config)
+	if err != nil {
+		return nil
 	}
-	// check if gate was updated
-	if prev.OriginGate != curr.OriginGate && curr.OriginGate != "" {
-		b.sendAlert(f, fmt.Sprintf("gate_change_%s", curr.OriginGate), slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":rotating_light: *Gate updated!* :rotating_light:\nPrevious: %s\nNew: %s", prev.OriginGate, curr.OriginGate), false, false),
-			nil,
-			nil,
-		), nil)
+	return state
+}
+
+func (b *LogicLoop) alertSent(subscriptionID string, alertID string) bool {
+	return shared.AlertAlreadySent(subscriptionID, alertID, b.Config)
+}
+
+// sendAlertEvent is the slack sink of the bus: it renders the alerts with the template
+// of the subscriber channel and sends them
+func (b *LogicLoop) sendAlertEvent(event events.FlightEvent) {
+	if event.Subscription == nil || event.Detail == nil {
+		return
 	}
-	// check if arrival gate was updated
-	if prev.DestGate != curr.DestGate {
-		b.sendAlert(f, fmt.Sprintf("arrival_gate_change_%s", curr.DestGate), slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":rotating_light: *Arrival gate updated!* :rotating_light:\nPrevious: %s\nNew: %s", prev.DestGate, curr.DestGate), false, false),
-			nil,
-			nil,
-		), nil)
+	sub := *event.Subscription
+
+	data := templates.AlertData{
+		Flight:       event.Flight,
+		Subscription: sub,
+		State:        event.State,
+		Prev:         event.Prev,
+		Previous:     event.Previous,
+		DepLoc:       templates.LoadLocation(event.Detail.Origin.TZ),
+		DestLoc:      templates.LoadLocation(event.Detail.Destination.TZ),
+		Now:          event.CreatedAt,
+		Locale:       shared.ResolveLocale(sub.SlackChannel, sub.SlackUserID, b.Config),
 	}
-	// check if arrival time is updated (by at least 15 minutes)
-	if arrBaseline := lastAnnounced(prev.LastAnnouncedArrEstimated, prev.ArrEstimated); curr.ArrEstimated != 0 && absDuration(curr.ArrEstimated-arrBaseline) >= estimateChangeThreshold {
-		prevTime := time.Unix(arrBaseline, 0).In(destLoc).Format(time.Kitchen)
-		currTime := time.Unix(curr.ArrEstimated, 0).In(destLoc).Format(time.Kitchen)
-		b.sendAlert(f, fmt.Sprintf("arrival_time_change_%d", curr.ArrEstimated), slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":rotating_light: *Arrival time updated!* :rotating_light:\nPrevious: %s\nNew: %s", prevTime, currTime), false, false),
-			nil,
-			nil,
-		), nil)
-		curr.LastAnnouncedArrEstimated = curr.ArrEstimated
+	if event.Connection != nil {
+		data.Connection = *event.Connection
 	}
 
-}
-
-// threshold for sending alerts on estimated time changes (15 minutes) (spam is not nice)
-const estimateChangeThreshold = 15 * 60
+	blocks, err := templates.Render(event.Type.Template(), sub.SlackChannel, data, b.Config)
+	if err != nil {
+		log.Printf("Error rendering alert for flight %s (%s): %v", event.Flight.ID, event.AlertID, err)
+		return
+	}
 
-// utils to calculate tresholds
-func lastAnnounced(announced, fallback int64) int64 {
-	if announced != 0 {
-		return announced
+	var mapImage *image.RGBA
+	if event.Type == events.InFlightUpdate {
+		mapImage = b.flightMap(event.Flight.ID, event.Detail)
 	}
-	return fallback
+	b.sendAlert(event.Flight, sub, event.AlertID, event.Type.Category(), data.Locale, blocks, mapImage)
 }
 
-func absDuration(d int64) int64 {
-	if d < 0 {
-		return -d
+// flightMap generates the map of a flight, at most once per poll whatever the number of subscribers
+func (b *LogicLoop) flightMap(flightID string, detail *flights.FlightDetail) *image.RGBA {
+	b.mapMu.Lock()
+	defer b.mapMu.Unlock()
+
+	if b.lastMapDetail == detail {
+		return b.lastMap
+	}
+	img, err := maps.GenerateMapFromFlightDetail(b.Config.TileStore, *detail)
+	if err != nil {
+		log.Printf("Error generating map for flight %s: %v", flightID, err)
 	}
-	return d
+	b.lastMapDetail, b.lastMap = detail, img
+	return img
 }
 
-func (b *LogicLoop) sendAlert(f shared.Flight, alertType string, blocks slack.Block, image *image.RGBA) {
+// sendAlert sends an alert to one subscriber, alerts_sent being keyed by subscription
+func (b *LogicLoop) sendAlert(f shared.Flight, sub shared.Subscription, alertType string, category string, locale string, blocks []slack.Block, image *image.RGBA) {
 	// add footer to blocks
 
+	footerText := i18n.T(locale, "alert.footer", f.FlightNumber, f.ID, sub.SlackUserID)
+	if sub.TravelerID != "" {
+		footerText = i18n.T(locale, "alert.footer_traveler", f.FlightNumber, f.ID, sub.SlackUserID, sub.TravelerID)
+	}
 	footer := slack.NewContextBlock("",
-		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("_flight %s - %s, tracked by <@%s>_", f.FlightNumber, f.ID, f.SlackUserID), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, footerText, false, false),
 	)
 
-	if shared.AlertAlreadySent(f.ID, alertType, b.Config) {
+	if shared.AlertAlreadySent(sub.ID, alertType, b.Config) {
 		return
 	}
 
-	if image != nil {
-		var buf bytes.Buffer
-		if err := png.Encode(&buf, image); err != nil {
-			log.Printf("Error encoding image for flight %s (%s): %v", f.ID, alertType, err)
-			return
-		}
-		uploadResponse, err := b.Config.SlackClient.UploadFileV2(slack.UploadFileV2Parameters{
-			Channel:  f.SlackChannel,
-			Filename: "flight_map.png",
-			Reader:   &buf,
-			FileSize: buf.Len(),
-			Title:    fmt.Sprintf("%s - %s", f.FlightNumber, time.Now().Format("2006-01-02")),
-			Blocks: slack.Blocks{
-				BlockSet: []slack.Block{blocks, footer},
-			},
-		})
-		if err != nil {
-			log.Printf("Error uploading image for flight %s (%s): %v", f.ID, alertType, err)
-		}
-		fmt.Printf("Uploaded file: %+v\n", uploadResponse)
+	// during quiet hours, non-critical alerts are kept for the summary
+	prefs := shared.ResolvePreferences(sub, b.Config)
+	if !shared.IsCriticalAlert(category) && prefs.InQuietHours(time.Now()) {
+		b.holdAlert(f, sub, alertType, slack.Blocks{BlockSet: append(blocks, footer)})
+		return
+	}
 
-	} else {
-		_, _, err := b.Config.SlackClient.PostMessage(
-			f.SlackChannel,
-			slack.MsgOptionBlocks(blocks, footer),
-		)
-		if err != nil {
-			log.Printf("Error sending alert for flight %s (%s): %v", f.ID, alertType, err)
-			return
-		}
+	// the outbox delivers the alert, so it is marked sent as soon as it is queued
+	_, err := b.enqueue(shared.OutboxMessage{
+		IdempotencyKey: sub.ID + ":" + alertType,
+		FlightID:       f.ID,
+		SubscriptionID: sub.ID,
+		AlertType:      alertType,
+		SlackChannel:   sub.SlackChannel,
+		Title:          fmt.Sprintf("%s - %s", f.FlightNumber, i18n.FormatDate(locale, time.Now())),
+	}, append(blocks, footer), image)
+	if err != nil {
+		log.Printf("Error queuing alert for flight %s (%s): %v", f.ID, alertType, err)
+		return
 	}
 
-	shared.MarkAlertSent(f.ID, alertType, b.Config)
-	log.Printf("Alert sent for flight %s: %s", f.ID, alertType)
+	shared.MarkAlertSent(sub.ID, alertType, b.Config)
 }
 
 func (b *LogicLoop) addFlight(f shared.Flight) {
@@ -3,14 +3,17 @@ package main
 import (
 	"database/sql"
 	"flight-tracker-slack/commands"
+	"flight-tracker-slack/eventsapi"
 	"flight-tracker-slack/flights"
 	"flight-tracker-slack/interactivity"
 	"flight-tracker-slack/maps"
 	"flight-tracker-slack/shared"
+	"flight-tracker-slack/socket"
 	"image/jpeg"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
@@ -39,11 +42,31 @@ func main() {
 		log.Fatal("SLACK_BOT_TOKEN environment variable not set")
 	}
 
+	// "socket" receives slack requests over a websocket, for bots without a public url
+	socketMode := os.Getenv("SLACK_MODE") == "socket"
+	appToken := os.Getenv("SLACK_APP_TOKEN")
+	if socketMode && appToken == "" {
+		log.Fatal("SLACK_APP_TOKEN environment variable not set (needed by socket mode)")
+	}
+
 	slackSigningSecret := os.Getenv("SLACK_SIGNING_SECRET")
-	if slackSigningSecret == "" {
+	if slackSigningSecret == "" && !socketMode {
 		log.Fatal("SLACK_SIGNING_SECRET environment variable not set")
 	}
 
+	// optional, maps are left out of the home tab without it
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+
+	// optional, the admin endpoints (e.g. /outbox) are only served with a token
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	adminAddr := os.Getenv("ADMIN_ADDR")
+	if adminAddr == "" {
+		adminAddr = "127.0.0.1:3001"
+	}
+
+	// webhooks can't reach local or private addresses, unless enabled to try them locally
+	allowPrivateWebhooks := os.Getenv("WEBHOOKS_ALLOW_PRIVATE") == "true"
+
 	tileStore := maps.NewTileStore("./data/map")
 
 	config := shared.Config{
@@ -52,6 +75,13 @@ func main() {
 		SlackToken:    slackToken,
 		TileStore:     tileStore,
 		SigningSecret: slackSigningSecret,
+		PublicURL:     publicURL,
+		SocketMode:    socketMode,
+		AppToken:      appToken,
+		AdminToken:    adminToken,
+		AdminAddr:     adminAddr,
+
+		AllowPrivateWebhooks: allowPrivateWebhooks,
 	}
 
 	Start(config)
@@ -75,17 +105,33 @@ func Start(config shared.Config) {
 
 	r := chi.NewRouter()
 
-	r.Post("/commands/{name}", func(w http.ResponseWriter, r *http.Request) {
-		name := chi.URLParam(r, "name")
-		log.Println("Received command: " + name)
-		commands.HandleCommand(name, w, r, config)
-	})
+	// in socket mode slack requests come through the websocket, and without a signing secret
+	// these endpoints couldn't verify where a request comes from
+	if !config.SocketMode {
+		r.Post("/commands/{name}", func(w http.ResponseWriter, r *http.Request) {
+			name := chi.URLParam(r, "name")
+			log.Println("Received command: " + name)
+			commands.HandleCommand(name, w, r, config)
+		})
 
-	// interactivity
+		// interactivity
 
-	r.Post("/slack/interactivity", func(w http.ResponseWriter, r *http.Request) {
-		interactivity.HandleInteraction(w, r, config)
-	})
+		r.Post("/slack/interactivity", func(w http.ResponseWriter, r *http.Request) {
+			interactivity.HandleInteraction(w, r, config)
+		})
+
+		// searches of external selects, routed like the other interactions
+
+		r.Post("/slack/options", func(w http.ResponseWriter, r *http.Request) {
+			interactivity.HandleInteraction(w, r, config)
+		})
+
+		// events api
+
+		r.Post("/slack/events", func(w http.ResponseWriter, r *http.Request) {
+			eventsapi.HandleEvent(w, r, config)
+		})
+	}
 
 	r.Get("/commands/{name}", func(w http.ResponseWriter, r *http.Request) {
 		name := chi.URLParam(r, "name")
@@ -137,6 +183,28 @@ func Start(config shared.Config) {
 
 	LogicLoop := NewLogicLoop(config)
 	go LogicLoop.Run()
+
+	// the http server keeps running for /health and /map
+	if config.SocketMode {
+		go func() {
+			if err := socket.Run(config); err != nil {
+				log.Fatal("Socket mode stopped: " + err.Error())
+			}
+		}()
+	}
+
+	// admin endpoints stay off the public listener
+	if config.AdminToken != "" {
+		go func() {
+			log.Println("Starting admin server on " + config.AdminAddr)
+			if err := http.ListenAndServe(config.AdminAddr, adminRouter(config)); err != nil {
+				log.Fatal("Error starting admin server: " + err.Error())
+			}
+		}()
+	} else {
+		log.Println("ADMIN_TOKEN not set, admin endpoints are disabled")
+	}
+
 	err = http.ListenAndServe(":"+config.Port, r)
 	if err != nil {
 		log.Fatal("Error starting server: " + err.Error())
@@ -153,6 +221,17 @@ func setupDatabase(db *sql.DB) {
         slack_user_id TEXT,
         departure INTEGER
     );
+    CREATE TABLE IF NOT EXISTS subscriptions (
+        id TEXT PRIMARY KEY,
+        flight_id TEXT,
+        slack_channel TEXT,
+        slack_user_id TEXT,
+        traveler_id TEXT NOT NULL DEFAULT '',
+        created_at INTEGER,
+        last_announced_dep_estimated INTEGER NOT NULL DEFAULT 0,
+        last_announced_arr_estimated INTEGER NOT NULL DEFAULT 0
+    );
+    -- flight_id holds the subscription id (which is the flight id for flights tracked before subscriptions)
     CREATE TABLE IF NOT EXISTS alerts_sent (
         flight_id TEXT,
         alert_type TEXT,
@@ -165,6 +244,13 @@ func setupDatabase(db *sql.DB) {
         origin_terminal TEXT,
         dest_gate TEXT,
         dest_terminal TEXT,
+        dest_baggage TEXT NOT NULL DEFAULT '',
+        tail TEXT NOT NULL DEFAULT '',
+        inbound_origin TEXT NOT NULL DEFAULT '',
+        inbound_arr_scheduled INTEGER NOT NULL DEFAULT 0,
+        inbound_arr_estimated INTEGER NOT NULL DEFAULT 0,
+        inbound_arr_actual INTEGER NOT NULL DEFAULT 0,
+        inbound_checked_at INTEGER NOT NULL DEFAULT 0,
         dep_scheduled INTEGER,
         dep_estimated INTEGER,
         dep_actual INTEGER,
@@ -181,6 +267,95 @@ func setupDatabase(db *sql.DB) {
         last_announced_dep_estimated INTEGER NOT NULL DEFAULT 0,
         last_announced_arr_estimated INTEGER NOT NULL DEFAULT 0
     );
+    CREATE TABLE IF NOT EXISTS preferences (
+        scope_id TEXT PRIMARY KEY,
+        gate_alerts INTEGER NOT NULL DEFAULT 1,
+        delay_alerts INTEGER NOT NULL DEFAULT 1,
+        takeoff_alerts INTEGER NOT NULL DEFAULT 1,
+        landing_alerts INTEGER NOT NULL DEFAULT 1,
+        in_flight_updates INTEGER NOT NULL DEFAULT 1,
+        delay_threshold INTEGER NOT NULL DEFAULT 900,
+        update_interval INTEGER NOT NULL DEFAULT 7200,
+        quiet_start INTEGER NOT NULL DEFAULT -1,
+        quiet_end INTEGER NOT NULL DEFAULT -1,
+        timezone TEXT NOT NULL DEFAULT '',
+        locale TEXT NOT NULL DEFAULT ''
+    );
+    CREATE TABLE IF NOT EXISTS alert_templates (
+        scope_id TEXT,
+        alert_type TEXT,
+        body TEXT,
+        updated_by TEXT,
+        updated_at INTEGER,
+        PRIMARY KEY (scope_id, alert_type)
+    );
+    CREATE TABLE IF NOT EXISTS held_alerts (
+        flight_id TEXT,
+        alert_type TEXT,
+        slack_channel TEXT,
+        slack_user_id TEXT,
+        blocks TEXT,
+        held_at INTEGER,
+        PRIMARY KEY (flight_id, alert_type, slack_channel)
+    );
+    CREATE TABLE IF NOT EXISTS webhooks (
+        id TEXT PRIMARY KEY,
+        scope_id TEXT,
+        url TEXT,
+        secret TEXT,
+        created_by TEXT,
+        created_at INTEGER
+    );
+    CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        id TEXT PRIMARY KEY,
+        webhook_id TEXT,
+        event_id TEXT,
+        event_type TEXT,
+        attempt INTEGER,
+        status_code INTEGER,
+        error TEXT,
+        duration INTEGER,
+        created_at INTEGER,
+        body TEXT NOT NULL DEFAULT '',
+        next_attempt_at INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at);
+    CREATE TABLE IF NOT EXISTS outbox (
+        id TEXT PRIMARY KEY,
+        idempotency_key TEXT UNIQUE,
+        flight_id TEXT,
+        subscription_id TEXT,
+        alert_type TEXT,
+        slack_channel TEXT,
+        blocks TEXT,
+        image BLOB,
+        title TEXT,
+        status TEXT NOT NULL DEFAULT 'pending',
+        attempts INTEGER NOT NULL DEFAULT 0,
+        next_attempt_at INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        created_at INTEGER,
+        sent_at INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS outbox_due ON outbox (status, next_attempt_at);
+    CREATE TABLE IF NOT EXISTS trips (
+        id TEXT PRIMARY KEY,
+        slack_channel TEXT,
+        slack_user_id TEXT,
+        traveler_id TEXT NOT NULL DEFAULT '',
+        created_at INTEGER
+    );
+    CREATE TABLE IF NOT EXISTS trip_legs (
+        trip_id TEXT,
+        subscription_id TEXT,
+        origin TEXT,
+        destination TEXT,
+        arrival INTEGER NOT NULL DEFAULT 0,
+        flight_number TEXT NOT NULL DEFAULT '',
+        departure INTEGER NOT NULL DEFAULT 0,
+        arrival_terminal TEXT NOT NULL DEFAULT '',
+        PRIMARY KEY (trip_id, subscription_id)
+    );
     `
 
 	_, err := db.Exec(schema)
@@ -194,6 +369,29 @@ func setupDatabase(db *sql.DB) {
 		"ALTER TABLE flight_state ADD COLUMN last_announced_arr_estimated INTEGER NOT NULL DEFAULT 0",
 		"UPDATE flight_state SET last_announced_dep_estimated = 0 WHERE last_announced_dep_estimated IS NULL",
 		"UPDATE flight_state SET last_announced_arr_estimated = 0 WHERE last_announced_arr_estimated IS NULL",
+		"ALTER TABLE preferences ADD COLUMN quiet_start INTEGER NOT NULL DEFAULT -1",
+		"ALTER TABLE preferences ADD COLUMN quiet_end INTEGER NOT NULL DEFAULT -1",
+		"ALTER TABLE preferences ADD COLUMN timezone TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE preferences ADD COLUMN locale TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE subscriptions ADD COLUMN traveler_id TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE webhook_deliveries ADD COLUMN body TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE webhook_deliveries ADD COLUMN next_attempt_at INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE flight_state ADD COLUMN dest_baggage TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE flight_state ADD COLUMN tail TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE flight_state ADD COLUMN inbound_origin TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE flight_state ADD COLUMN inbound_arr_scheduled INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE flight_state ADD COLUMN inbound_arr_estimated INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE flight_state ADD COLUMN inbound_arr_actual INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE flight_state ADD COLUMN inbound_checked_at INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE trip_legs ADD COLUMN flight_number TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE trip_legs ADD COLUMN departure INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE trip_legs ADD COLUMN arrival_terminal TEXT NOT NULL DEFAULT ''",
+		// flights tracked before subscriptions existed get one subscription with the same id,
+		// so the alerts_sent rows (keyed by subscription) still match
+		`INSERT OR IGNORE INTO subscriptions (id, flight_id, slack_channel, slack_user_id, created_at, last_announced_dep_estimated, last_announced_arr_estimated)
+			SELECT f.id, f.id, f.slack_channel, f.slack_user_id, 0, COALESCE(s.last_announced_dep_estimated, 0), COALESCE(s.last_announced_arr_estimated, 0)
+			FROM flights f LEFT JOIN flight_state s ON s.flight_id = f.id
+			WHERE f.id NOT IN (SELECT flight_id FROM subscriptions)`,
 	}
 	for _, m := range migrations {
 		db.Exec(m)
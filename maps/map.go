@@ -1,6 +1,7 @@
 package maps
 
 import (
+	"flight-tracker-slack/assets"
 	"flight-tracker-slack/flights"
 	"fmt"
 	"image"
@@ -75,7 +76,7 @@ func GreatCirclePoints(lat1, lon1, lat2, lon2 float64, n int) [][2]float64 {
 
 func init() {
 	// load everything from assets/planes into planeIcons
-	var dir, err = os.ReadDir("assets/planes")
+	var dir, err = assets.Files.ReadDir("planes")
 
 	if err != nil {
 		panic(err)
@@ -88,15 +89,10 @@ func init() {
 		if entry.Name()[len(entry.Name())-4:] != ".svg" {
 			continue
 		}
-		filePath := "assets/planes/" + entry.Name()
-		f, err := os.Open(filePath)
-		if err != nil {
-			panic(err)
-		}
-		defer f.Close()
+		filePath := "planes/" + entry.Name()
 
 		// now recolor the svg file's fill and stroke attributes
-		svgData, err := os.ReadFile(filePath)
+		svgData, err := assets.Files.ReadFile(filePath)
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flight-tracker-slack/shared"
+	"image"
+	"image/png"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// the sender retries failed messages after outboxBaseDelay, doubling every attempt up to outboxMaxDelay,
+// and gives up after outboxMaxAttempts: the message stays in the outbox as dead
+const (
+	outboxBaseDelay   = 30 * time.Second
+	outboxMaxDelay    = 30 * time.Minute
+	outboxMaxAttempts = 8
+	outboxBatchSize   = 200
+	outboxPollEvery   = 5 * time.Second
+	outboxKeepSent    = 7 * 24 * time.Hour
+)
+
+// enqueue stores a message in the outbox and wakes the sender up.
+// It returns false if a message with the same idempotency key was already queued.
+func (b *LogicLoop) enqueue(msg shared.OutboxMessage, blocks []slack.Block, img *image.RGBA) (bool, error) {
+	encoded, err := json.Marshal(slack.Blocks{BlockSet: blocks})
+	if err != nil {
+		return false, err
+	}
+	if img != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return false, err
+		}
+		msg.Image = buf.Bytes()
+	}
+
+	now := time.Now().Unix()
+	msg.ID = uuid.New().String()
+	msg.Blocks = string(encoded)
+	msg.Status = shared.OutboxPending
+	msg.NextAttemptAt = now
+	msg.CreatedAt = now
+
+	queued, err := shared.EnqueueMessage(msg, b.Config)
+	if err != nil || !queued {
+		return queued, err
+	}
+
+	select {
+	case b.outboxWake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// runOutbox is the sender: it delivers the due messages of the outbox until the process stops.
+// Messages are only marked sent once slack accepted them, so a crash mid-send means they're sent again on restart.
+func (b *LogicLoop) runOutbox() {
+	ticker := time.NewTicker(outboxPollEvery)
+	defer ticker.Stop()
+
+	for {
+		b.sendDueMessages()
+		if err := shared.PurgeSentMessages(time.Now().Add(-outboxKeepSent).Unix(), b.Config); err != nil {
+			log.Println("Error purging outbox:", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-b.outboxWake:
+		}
+	}
+}
+
+// recordResult marks a message sent, or schedules its next attempt
+func (b *LogicLoop) recordResult(msg shared.OutboxMessage, err error) {
+	if err == nil {
+		if err := shared.MarkMessageSent(msg.ID, time.Now().Unix(), b.Config); err != nil {
+			log.Printf("Error marking message %s as sent: %v", msg.ID, err)
+		}
+		log.Printf("Alert sent for flight %s to %s: %s", msg.FlightID, msg.SlackChannel, msg.AlertType)
+		return
+	}
+
+	attempts := msg.Attempts + 1
+	dead := attempts >= outboxMaxAttempts
+	next := time.Now().Add(outboxBackoff(attempts))
+	if dead {
+		log.Printf("Giving up on alert for flight %s to %s (%s) after %d attempts: %v", msg.FlightID, msg.SlackChannel, msg.AlertType, attempts, err)
+	} else {
+		log.Printf("Error sending alert for flight %s to %s (%s), attempt %d, retrying at %s: %v", msg.FlightID, msg.SlackChannel, msg.AlertType, attempts, next.Format(time.Kitchen), err)
+	}
+	if err := shared.MarkMessageFailed(msg.ID, attempts, next.Unix(), err.Error(), dead, b.Config); err != nil {
+		log.Printf("Error marking message %s as failed: %v", msg.ID, err)
+	}
+}
+
+// outboxBackoff is the delay before the next attempt of a message that failed attempts times
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
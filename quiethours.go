@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func (b *LogicLoop) holdAlert(f shared.Flight, sub shared.Subscription, alertType string, blocks slack.Blocks) {
+	encoded, err := json.Marshal(blocks)
+	if err != nil {
+		log.Printf("Error encoding held alert for flight %s (%s): %v", f.ID, alertType, err)
+		return
+	}
+
+	err = shared.HoldAlert(shared.HeldAlert{
+		FlightID:     f.ID,
+		AlertType:    alertType,
+		SlackChannel: sub.SlackChannel,
+		SlackUserID:  sub.SlackUserID,
+		Blocks:       string(encoded),
+		HeldAt:       time.Now().Unix(),
+	}, b.Config)
+	if err != nil {
+		log.Printf("Error holding alert for flight %s (%s): %v", f.ID, alertType, err)
+		return
+	}
+
+	// the alert is stored, so it must not be detected again
+	shared.MarkAlertSent(sub.ID, alertType, b.Config)
+	log.Printf("Alert held during quiet hours for flight %s: %s", f.ID, alertType)
+}
+
+// flushHeldAlerts sends one summary per channel whose quiet hours are over
+func (b *LogicLoop) flushHeldAlerts() {
+	held, err := shared.GetHeldAlerts(b.Config)
+	if err != nil {
+		log.Println("Error loading held alerts:", err)
+		return
+	}
+	if len(held) == 0 {
+		return
+	}
+
+	var channels []string
+	byChannel := make(map[string][]shared.HeldAlert)
+	for _, h := range held {
+		if _, exists := byChannel[h.SlackChannel]; !exists {
+			channels = append(channels, h.SlackChannel)
+		}
+		byChannel[h.SlackChannel] = append(byChannel[h.SlackChannel], h)
+	}
+
+	for _, channel := range channels {
+		alerts := byChannel[channel]
+		prefs := shared.ResolvePreferences(shared.Subscription{SlackChannel: channel, SlackUserID: alerts[0].SlackUserID}, b.Config)
+		if prefs.InQuietHours(time.Now()) {
+			continue
+		}
+		b.sendHeldSummary(channel, shared.ResolveLocale(channel, alerts[0].SlackUserID, b.Config), alerts)
+	}
+}
+
+func (b *LogicLoop) sendHeldSummary(channel string, locale string, alerts []shared.HeldAlert) {
+	header := slack.NewSectionBlock(
+		slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "quiet_hours.summary"), false, false),
+		nil,
+		nil,
+	)
+
+	blocks := []slack.Block{header}
+	var pending []shared.HeldAlert
+
+	send := func() bool {
+		if len(pending) == 0 {
+			return true
+		}
+		// held alerts are unique per channel, so the first one identifies the summary
+		_, err := b.enqueue(shared.OutboxMessage{
+			IdempotencyKey: "summary:" + channel + ":" + pending[0].FlightID + ":" + pending[0].AlertType,
+			FlightID:       pending[0].FlightID,
+			AlertType:      "quiet_hours_summary",
+			SlackChannel:   channel,
+		}, blocks, nil)
+		if err != nil {
+			log.Printf("Error queuing quiet hours summary to %s: %v", channel, err)
+			return false
+		}
+		for _, h := range pending {
+			shared.DeleteHeldAlert(h.FlightID, h.AlertType, h.SlackChannel, b.Config)
+		}
+		return true
+	}
+
+	for _, h := range alerts {
+		var alertBlocks slack.Blocks
+		if err := json.Unmarshal([]byte(h.Blocks), &alertBlocks); err != nil {
+			log.Printf("Error decoding held alert for flight %s (%s): %v", h.FlightID, h.AlertType, err)
+			shared.DeleteHeldAlert(h.FlightID, h.AlertType, h.SlackChannel, b.Config)
+			continue
+		}
+
+		if len(blocks)+len(alertBlocks.BlockSet)+1 > shared.MaxBlocksPerMessage {
+			if !send() {
+				return
+			}
+			blocks = []slack.Block{header}
+			pending = nil
+		}
+
+		blocks = append(blocks, slack.NewDividerBlock())
+		blocks = append(blocks, alertBlocks.BlockSet...)
+		pending = append(pending, h)
+	}
+
+	if len(pending) > 0 && send() {
+		log.Printf("Sent quiet hours summary to %s (%d alerts)", channel, len(alerts))
+	}
+}
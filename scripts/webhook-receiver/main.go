@@ -0,0 +1,59 @@
+// A local receiver to try the webhooks: it checks the signature of every event and prints it.
+//
+//	go run ./scripts/webhook-receiver -secret <signing secret> -port 4000
+//	/flight-webhooks add http://localhost:4000/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"flight-tracker-slack/webhooks"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	port := flag.String("port", "4000", "port to listen on")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "signing secret of the webhook (or WEBHOOK_SECRET)")
+	fail := flag.Bool("fail", false, "answer 500 to every event, to try the retries")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("a signing secret is needed, use -secret or WEBHOOK_SECRET")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := webhooks.Verify(*secret, r.Header, body); err != nil {
+			log.Printf("rejected %s: %v", r.Header.Get(webhooks.HeaderEventID), err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var event webhooks.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			log.Printf("invalid event: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		pretty, _ := json.MarshalIndent(event, "", "  ")
+		log.Printf("%s (%s)\n%s", event.Type, event.ID, pretty)
+
+		if *fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Println("Listening for webhooks on port " + *port)
+	log.Fatal(http.ListenAndServe(":"+*port, nil))
+}
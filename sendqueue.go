@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flight-tracker-slack/shared"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// slack allows about one message per second in a channel, short bursts aside
+const channelSendInterval = time.Second
+
+// sendQueue paces the messages of the outbox per channel
+type sendQueue struct {
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+// wait blocks until a message can be sent to the channel, and books the slot
+func (q *sendQueue) wait(channel string) {
+	q.mu.Lock()
+	next := q.lastSent[channel].Add(channelSendInterval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	q.lastSent[channel] = next
+	q.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+}
+
+// sendDueMessages delivers the due messages of the outbox, channels being served in parallel
+// and the messages of a channel in order
+func (b *LogicLoop) sendDueMessages() {
+	for {
+		messages, err := shared.GetDueMessages(time.Now().Unix(), outboxBatchSize, b.Config)
+		if err != nil {
+			log.Println("Error loading outbox:", err)
+			return
+		}
+
+		var channels []string
+		byChannel := make(map[string][]shared.OutboxMessage)
+		for _, msg := range messages {
+			if _, exists := byChannel[msg.SlackChannel]; !exists {
+				channels = append(channels, msg.SlackChannel)
+			}
+			byChannel[msg.SlackChannel] = append(byChannel[msg.SlackChannel], msg)
+		}
+
+		var wg sync.WaitGroup
+		for _, channel := range channels {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.sendChannel(channel, byChannel[channel])
+			}()
+		}
+		wg.Wait()
+
+		if len(messages) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// sendChannel sends the messages of one channel, stopping at the first rate limit:
+// the rest of the channel waits for the Retry-After given by slack, without counting as an attempt
+func (b *LogicLoop) sendChannel(channel string, messages []shared.OutboxMessage) {
+	batches := coalesce(messages)
+	for i, batch := range batches {
+		b.queue.wait(channel)
+
+		err := b.deliverBatch(batch)
+		var rateLimited *slack.RateLimitedError
+		if errors.As(err, &rateLimited) {
+			waiting := 0
+			for _, rest := range batches[i:] {
+				waiting += len(rest)
+			}
+			until := time.Now().Add(rateLimited.RetryAfter)
+			log.Printf("Rate limited on %s, %d alerts waiting until %s", channel, waiting, until.Format(time.Kitchen))
+			if err := shared.DelayChannelMessages(channel, until.Unix(), b.Config); err != nil {
+				log.Printf("Error delaying messages of %s: %v", channel, err)
+			}
+
+			b.queue.mu.Lock()
+			b.queue.lastSent[channel] = until
+			b.queue.mu.Unlock()
+			return
+		}
+
+		for _, msg := range batch {
+			b.recordResult(msg, err)
+		}
+	}
+}
+
+// coalesce groups consecutive messages about the same flight into one, so a backlog doesn't turn into a burst.
+// Messages with an image are uploaded on their own.
+func coalesce(messages []shared.OutboxMessage) [][]shared.OutboxMessage {
+	var batches [][]shared.OutboxMessage
+	blocks := 0
+	for _, msg := range messages {
+		var decoded slack.Blocks
+		size := -1
+		if len(msg.Image) == 0 && json.Unmarshal([]byte(msg.Blocks), &decoded) == nil {
+			size = len(decoded.BlockSet)
+		}
+
+		if n := len(batches); size >= 0 && n > 0 && blocks >= 0 {
+			last := batches[n-1]
+			if last[0].FlightID == msg.FlightID && blocks+size+1 <= shared.MaxBlocksPerMessage {
+				batches[n-1] = append(last, msg)
+				blocks += size + 1
+				continue
+			}
+		}
+		batches = append(batches, []shared.OutboxMessage{msg})
+		blocks = size
+	}
+	return batches
+}
+
+// deliverBatch sends coalesced messages as one, separated by dividers
+func (b *LogicLoop) deliverBatch(batch []shared.OutboxMessage) error {
+	if len(batch) == 1 {
+		return b.deliverMessage(batch[0])
+	}
+
+	var merged []slack.Block
+	for i, msg := range batch {
+		var blocks slack.Blocks
+		if err := json.Unmarshal([]byte(msg.Blocks), &blocks); err != nil {
+			return err
+		}
+		if i > 0 {
+			merged = append(merged, slack.NewDividerBlock())
+		}
+		merged = append(merged, blocks.BlockSet...)
+	}
+
+	encoded, err := json.Marshal(slack.Blocks{BlockSet: merged})
+	if err != nil {
+		return err
+	}
+	msg := batch[0]
+	msg.Blocks = string(encoded)
+	return b.deliverMessage(msg)
+}
+
+func (b *LogicLoop) deliverMessage(msg shared.OutboxMessage) error {
+	var blocks slack.Blocks
+	if err := json.Unmarshal([]byte(msg.Blocks), &blocks); err != nil {
+		return err
+	}
+
+	// dms go through conversations.open, the bot doesn't need to be in any channel
+	channel, err := shared.DeliveryChannel(msg.SlackChannel, b.Config)
+	if err != nil {
+		return err
+	}
+
+	if len(msg.Image) > 0 {
+		_, err = b.Config.SlackClient.UploadFileV2(slack.UploadFileV2Parameters{
+			Channel:  channel,
+			Filename: "flight_map.png",
+			Reader:   bytes.NewReader(msg.Image),
+			FileSize: len(msg.Image),
+			Title:    msg.Title,
+			Blocks:   blocks,
+		})
+		return err
+	}
+
+	if len(blocks.BlockSet) == 0 {
+		return errors.New("empty message")
+	}
+	_, _, err = b.Config.SlackClient.PostMessage(channel, slack.MsgOptionBlocks(blocks.BlockSet...))
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flight-tracker-slack/shared"
+	"reflect"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestCoalesce(t *testing.T) {
+	message := func(id string, flightID string, blocks int) shared.OutboxMessage {
+		set := make([]slack.Block, blocks)
+		for i := range set {
+			set[i] = slack.NewDividerBlock()
+		}
+		encoded, err := json.Marshal(slack.Blocks{BlockSet: set})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return shared.OutboxMessage{ID: id, FlightID: flightID, Blocks: string(encoded)}
+	}
+	withImage := message("image", "A", 2)
+	withImage.Image = []byte("png")
+
+	tests := []struct {
+		name     string
+		messages []shared.OutboxMessage
+		want     [][]string
+	}{
+		{
+			name:     "same flight",
+			messages: []shared.OutboxMessage{message("1", "A", 3), message("2", "A", 3), message("3", "A", 3)},
+			want:     [][]string{{"1", "2", "3"}},
+		},
+		{
+			name:     "another flight in between",
+			messages: []shared.OutboxMessage{message("1", "A", 3), message("2", "B", 3), message("3", "A", 3)},
+			want:     [][]string{{"1"}, {"2"}, {"3"}},
+		},
+		{
+			name:     "images on their own",
+			messages: []shared.OutboxMessage{message("1", "A", 3), withImage, message("2", "A", 3)},
+			want:     [][]string{{"1"}, {"image"}, {"2"}},
+		},
+		{
+			// 30 blocks, then 19 more and a divider make 50, the next one would go over
+			name:     "blocks limit",
+			messages: []shared.OutboxMessage{message("1", "A", 30), message("2", "A", 19), message("3", "A", 1)},
+			want:     [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:     "undecodable blocks",
+			messages: []shared.OutboxMessage{message("1", "A", 3), {ID: "2", FlightID: "A", Blocks: "{"}, message("3", "A", 3)},
+			want:     [][]string{{"1"}, {"2"}, {"3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := coalesce(tt.messages)
+			var got [][]string
+			for _, batch := range batches {
+				var ids []string
+				for _, msg := range batch {
+					ids = append(ids, msg.ID)
+				}
+				got = append(got, ids)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got batches %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
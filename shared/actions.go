@@ -0,0 +1,67 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ActionValue is the state carried by a button or a select option, in its value.
+// It names exactly what the action applies to, instead of leaving the handler guess it from the action id.
+type ActionValue struct {
+	FlightID       string `json:"f,omitempty"`
+	SubscriptionID string `json:"s,omitempty"`
+	Channel        string `json:"c,omitempty"`
+	// Track is the request prefilled in the tracking modal, for the buttons opening it
+	Track *TrackRequest `json:"t,omitempty"`
+}
+
+var ErrInvalidAction = errors.New("invalid or tampered action value")
+
+// EncodeAction signs an action value, as "<base64 json>.<base64 signature>"
+func EncodeAction(value ActionValue, config Config) (string, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(actionSignature(encoded, config)), nil
+}
+
+// DecodeAction checks the signature of an action value encoded by EncodeAction and decodes it
+func DecodeAction(value string, config Config) (ActionValue, error) {
+	var decoded ActionValue
+
+	encoded, signature, found := strings.Cut(value, ".")
+	if !found {
+		return decoded, ErrInvalidAction
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, actionSignature(encoded, config)) {
+		return decoded, ErrInvalidAction
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return decoded, ErrInvalidAction
+	}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return decoded, ErrInvalidAction
+	}
+	return decoded, nil
+}
+
+// actionSignature is a truncated hmac of the value, keyed with the signing secret
+// (or with the bot token in socket mode, where there may be no signing secret)
+func actionSignature(encoded string, config Config) []byte {
+	key := config.SigningSecret
+	if key == "" {
+		key = config.SlackToken
+	}
+	mac := hmac.New(sha256.New, []byte("action:"+key))
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)[:16]
+}
@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestActionSigning(t *testing.T) {
+	config := Config{SigningSecret: "secret"}
+	value := ActionValue{FlightID: "flight", SubscriptionID: "sub", Track: &TrackRequest{FlightNumber: "AF123"}}
+
+	encoded, err := EncodeAction(value, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeAction(encoded, config)
+	if err != nil {
+		t.Fatalf("decoding a signed value: %v", err)
+	}
+	if decoded.FlightID != "flight" || decoded.SubscriptionID != "sub" || decoded.Track == nil || decoded.Track.FlightNumber != "AF123" {
+		t.Errorf("got %+v, want %+v", decoded, value)
+	}
+
+	payload, signature, _ := strings.Cut(encoded, ".")
+	other, _ := EncodeAction(ActionValue{FlightID: "other"}, config)
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name   string
+		value  string
+		config Config
+	}{
+		{"unsigned json", `{"f":"flight"}`, config},
+		{"payload swapped", otherPayload + "." + signature, config},
+		{"signature dropped", payload, config},
+		{"signature truncated", payload + "." + signature[:len(signature)-2], config},
+		{"other secret", encoded, Config{SigningSecret: "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeAction(tt.value, tt.config); !errors.Is(err, ErrInvalidAction) {
+				t.Errorf("got %v, want ErrInvalidAction", err)
+			}
+		})
+	}
+}
+
+func TestActionSigningWithoutSigningSecret(t *testing.T) {
+	// in socket mode, the bot token signs the values
+	encoded, err := EncodeAction(ActionValue{Channel: "C1"}, Config{SlackToken: "xoxb-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeAction(encoded, Config{SlackToken: "xoxb-1"}); err != nil {
+		t.Errorf("decoding with the same token: %v", err)
+	}
+	if _, err := DecodeAction(encoded, Config{SlackToken: "xoxb-2"}); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("decoding with another token: got %v, want ErrInvalidAction", err)
+	}
+}
@@ -20,6 +20,19 @@ func structColumns(ptr any) (columns []string, scanDest []any) {
 	return
 }
 
+// structValues returns the values of the fields with a db tag, in the order of structColumns
+func structValues(s any) []any {
+	v := reflect.ValueOf(s)
+	t := v.Type()
+	vals := make([]any, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("db") != "" {
+			vals = append(vals, v.Field(i).Interface())
+		}
+	}
+	return vals
+}
+
 func placeholders(n int) string {
 	return strings.Repeat("?, ", n-1) + "?"
 }
@@ -51,9 +64,21 @@ func GetFlight(id string, config Config) (*Flight, error) {
 	return &f, nil
 }
 
+// UntrackFlight stops tracking a flight for all of its subscribers.
+// Its trip legs stay, for the connection to the next flight, until none of the trip is tracked.
 func UntrackFlight(id string, config Config) error {
-	_, err := config.UserDB.Exec("DELETE FROM flights WHERE id=$1", id)
-	return err
+	if err := keepTripLegs(id, config); err != nil {
+		return err
+	}
+	_, err := config.UserDB.Exec("DELETE FROM subscriptions WHERE flight_id=$1", id)
+	if err != nil {
+		return err
+	}
+	_, err = config.UserDB.Exec("DELETE FROM flights WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	return pruneTrips(config)
 }
 
 type FlightFilter struct {
@@ -97,14 +122,14 @@ func SaveFlightState(state FlightState, config Config) error {
 	return err
 }
 
-func AlertAlreadySent(flightID, alertType string, config Config) bool {
-	row := config.UserDB.QueryRow("SELECT 1 FROM alerts_sent WHERE flight_id = ? AND alert_type = ?", flightID, alertType)
+func AlertAlreadySent(subscriptionID, alertType string, config Config) bool {
+	row := config.UserDB.QueryRow("SELECT 1 FROM alerts_sent WHERE flight_id = ? AND alert_type = ?", subscriptionID, alertType)
 	var exists int
 	return row.Scan(&exists) == nil
 }
 
-func MarkAlertSent(flightID, alertType string, config Config) error {
-	_, err := config.UserDB.Exec("INSERT OR IGNORE INTO alerts_sent (flight_id, alert_type) VALUES (?, ?)", flightID, alertType)
+func MarkAlertSent(subscriptionID, alertType string, config Config) error {
+	_, err := config.UserDB.Exec("INSERT OR IGNORE INTO alerts_sent (flight_id, alert_type) VALUES (?, ?)", subscriptionID, alertType)
 	return err
 }
 
@@ -0,0 +1,55 @@
+package shared
+
+import (
+	"flight-tracker-slack/i18n"
+	"log"
+	"sync"
+	"time"
+)
+
+// slack user locales are cached, alerts and commands would otherwise call users.info every time
+const userLocaleTTL = 1 * time.Hour
+
+type cachedLocale struct {
+	locale    string
+	fetchedAt time.Time
+}
+
+var userLocales sync.Map
+
+// ResolveLocale returns the language to use in a channel (or a dm) for a user:
+// the language chosen in the channel settings, then in the user settings, then the slack user locale
+func ResolveLocale(channelID string, userID string, config Config) string {
+	for _, scope := range []string{channelID, userID} {
+		if scope == "" {
+			continue
+		}
+		prefs, err := GetPreferences(scope, config)
+		if err == nil && i18n.Normalize(prefs.Locale) != "" {
+			return i18n.Normalize(prefs.Locale)
+		}
+	}
+
+	if userID != "" {
+		if locale := SlackUserLocale(userID, config); locale != "" {
+			return locale
+		}
+	}
+	return i18n.DefaultLocale
+}
+
+// SlackUserLocale returns the supported locale of a slack user, or "" if it isn't supported
+func SlackUserLocale(userID string, config Config) string {
+	if cached, ok := userLocales.Load(userID); ok && time.Since(cached.(cachedLocale).fetchedAt) < userLocaleTTL {
+		return cached.(cachedLocale).locale
+	}
+
+	user, err := config.SlackClient.GetUserInfo(userID)
+	if err != nil {
+		log.Printf("Error fetching locale of user %s: %v\n", userID, err)
+		return ""
+	}
+	locale := i18n.Normalize(user.Locale)
+	userLocales.Store(userID, cachedLocale{locale: locale, fetchedAt: time.Now()})
+	return locale
+}
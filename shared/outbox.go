@@ -0,0 +1,100 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// statuses of the outbox messages
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // gave up after too many attempts
+)
+
+// EnqueueMessage adds a message to the outbox, returning false if one with the same idempotency key is already there
+func EnqueueMessage(msg OutboxMessage, config Config) (bool, error) {
+	cols, _ := structColumns(&msg)
+	query := fmt.Sprintf("INSERT OR IGNORE INTO outbox (%s) VALUES (%s)", strings.Join(cols, ", "), placeholders(len(cols)))
+
+	res, err := config.UserDB.Exec(query, structValues(msg)...)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetDueMessages returns the pending messages whose next attempt is due, oldest first
+func GetDueMessages(now int64, limit int, config Config) ([]OutboxMessage, error) {
+	var m OutboxMessage
+	cols, _ := structColumns(&m)
+	query := fmt.Sprintf("SELECT %s FROM outbox WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at ASC, created_at ASC LIMIT ?", strings.Join(cols, ", "))
+
+	rows, err := config.UserDB.Query(query, OutboxPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		_, dest := structColumns(&msg)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func MarkMessageSent(id string, sentAt int64, config Config) error {
+	_, err := config.UserDB.Exec("UPDATE outbox SET status = ?, sent_at = ?, attempts = attempts + 1, last_error = '', image = NULL WHERE id = ?", OutboxSent, sentAt, id)
+	return err
+}
+
+// MarkMessageFailed records a failed attempt, the message being retried at nextAttemptAt (or dead-lettered)
+func MarkMessageFailed(id string, attempts int, nextAttemptAt int64, lastError string, dead bool, config Config) error {
+	status := OutboxPending
+	if dead {
+		status = OutboxDead
+	}
+	_, err := config.UserDB.Exec("UPDATE outbox SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?", status, attempts, nextAttemptAt, lastError, id)
+	return err
+}
+
+// PurgeSentMessages deletes the messages sent before the given time, dead ones being kept for inspection
+func PurgeSentMessages(before int64, config Config) error {
+	_, err := config.UserDB.Exec("DELETE FROM outbox WHERE status = ? AND sent_at < ?", OutboxSent, before)
+	return err
+}
+
+// DelayChannelMessages pushes back the pending messages of a channel, e.g. when slack rate limits it
+func DelayChannelMessages(channel string, until int64, config Config) error {
+	_, err := config.UserDB.Exec("UPDATE outbox SET next_attempt_at = MAX(next_attempt_at, ?) WHERE status = ? AND slack_channel = ?", until, OutboxPending, channel)
+	return err
+}
+
+// GetOutboxBacklog returns, for every channel with undelivered messages, how many are waiting or dead
+func GetOutboxBacklog(config Config) ([]OutboxBacklog, error) {
+	rows, err := config.UserDB.Query(`SELECT slack_channel,
+		SUM(CASE WHEN status = ? THEN 1 ELSE 0 END),
+		SUM(CASE WHEN status = ? THEN 1 ELSE 0 END),
+		COALESCE(MIN(CASE WHEN status = ? THEN created_at END), 0)
+		FROM outbox WHERE status != ? GROUP BY slack_channel ORDER BY slack_channel`, OutboxPending, OutboxDead, OutboxPending, OutboxSent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backlog []OutboxBacklog
+	for rows.Next() {
+		var b OutboxBacklog
+		if err := rows.Scan(&b.Channel, &b.Pending, &b.Dead, &b.OldestPending); err != nil {
+			return nil, err
+		}
+		backlog = append(backlog, b)
+	}
+	return backlog, rows.Err()
+}
@@ -0,0 +1,144 @@
+package shared
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// defaults used when neither the channel nor the user saved any preferences
+
+// threshold for sending alerts on estimated time changes (15 minutes) (spam is not nice)
+const DefaultDelayThreshold = 15 * 60
+
+// in-flight updates are sent every 2 hours
+const DefaultUpdateInterval = 2 * 60 * 60
+
+// alert categories, used to check the preferences before sending an alert
+const (
+	AlertCategoryGate     = "gate"
+	AlertCategoryDelay    = "delay"
+	AlertCategoryTakeoff  = "takeoff"
+	AlertCategoryLanding  = "landing"
+	AlertCategoryInFlight = "in_flight"
+)
+
+func DefaultPreferences(scopeID string) Preferences {
+	return Preferences{
+		ScopeID:         scopeID,
+		GateAlerts:      true,
+		DelayAlerts:     true,
+		TakeoffAlerts:   true,
+		LandingAlerts:   true,
+		InFlightUpdates: true,
+		DelayThreshold:  DefaultDelayThreshold,
+		UpdateInterval:  DefaultUpdateInterval,
+		QuietStart:      -1,
+		QuietEnd:        -1,
+	}
+}
+
+// IsCriticalAlert returns whether an alert category is still sent during quiet hours
+func IsCriticalAlert(category string) bool {
+	return category == AlertCategoryGate || category == AlertCategoryDelay
+}
+
+// InQuietHours returns whether t falls in the quiet hours window (in the preferences timezone)
+func (p Preferences) InQuietHours(t time.Time) bool {
+	if p.QuietStart < 0 || p.QuietEnd < 0 || p.QuietStart == p.QuietEnd {
+		return false
+	}
+
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil || p.Timezone == "" {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	minutes := local.Hour()*60 + local.Minute()
+
+	// the window can wrap around midnight (e.g. 22:00 → 07:00)
+	if p.QuietStart < p.QuietEnd {
+		return minutes >= p.QuietStart && minutes < p.QuietEnd
+	}
+	return minutes >= p.QuietStart || minutes < p.QuietEnd
+}
+
+// Allows returns whether alerts of the given category should be sent
+func (p Preferences) Allows(category string) bool {
+	switch category {
+	case AlertCategoryGate:
+		return p.GateAlerts
+	case AlertCategoryDelay:
+		return p.DelayAlerts
+	case AlertCategoryTakeoff:
+		return p.TakeoffAlerts
+	case AlertCategoryLanding:
+		return p.LandingAlerts
+	case AlertCategoryInFlight:
+		return p.InFlightUpdates
+	}
+	return true
+}
+
+func GetPreferences(scopeID string, config Config) (*Preferences, error) {
+	var p Preferences
+	cols, dest := structColumns(&p)
+	query := fmt.Sprintf("SELECT %s FROM preferences WHERE scope_id = ?", strings.Join(cols, ", "))
+
+	err := config.UserDB.QueryRow(query, scopeID).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func SavePreferences(prefs Preferences, config Config) error {
+	cols, _ := structColumns(&prefs)
+	vals := make([]any, 0, len(cols))
+	v := reflect.ValueOf(prefs)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("db") != "" {
+			vals = append(vals, v.Field(i).Interface())
+		}
+	}
+
+	query := fmt.Sprintf(`INSERT INTO preferences (%s) VALUES (%s)
+		ON CONFLICT(scope_id) DO UPDATE SET %s`,
+		strings.Join(cols, ", "),
+		placeholders(len(cols)),
+		upsertSet(cols, "scope_id"))
+
+	_, err := config.UserDB.Exec(query, vals...)
+	return err
+}
+
+// GetPreferencesOrDefault returns the saved preferences for the scope, or the defaults if there are none
+func GetPreferencesOrDefault(scopeID string, config Config) (Preferences, error) {
+	prefs, err := GetPreferences(scopeID, config)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPreferences(scopeID), nil
+	}
+	if err != nil {
+		return DefaultPreferences(scopeID), err
+	}
+	return *prefs, nil
+}
+
+// ResolvePreferences returns the preferences that apply to a subscription:
+// the channel ones first, then the ones of the user who tracked it, then the defaults
+func ResolvePreferences(sub Subscription, config Config) Preferences {
+	for _, scope := range []string{sub.SlackChannel, sub.SlackUserID} {
+		if scope == "" {
+			continue
+		}
+		prefs, err := GetPreferences(scope, config)
+		if err == nil {
+			return *prefs
+		}
+	}
+	return DefaultPreferences(sub.SlackChannel)
+}
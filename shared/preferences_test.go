@@ -0,0 +1,60 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 6, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		start    int
+		end      int
+		timezone string
+		t        time.Time
+		want     bool
+	}{
+		{"disabled", -1, -1, "", at(23, 0), false},
+		{"empty window", 600, 600, "", at(10, 0), false},
+		{"inside a daytime window", 9 * 60, 17 * 60, "", at(12, 0), true},
+		{"end of a daytime window", 9 * 60, 17 * 60, "", at(17, 0), false},
+		{"night, before midnight", 22 * 60, 7 * 60, "", at(23, 30), true},
+		{"night, after midnight", 22 * 60, 7 * 60, "", at(6, 59), true},
+		{"night, morning", 22 * 60, 7 * 60, "", at(7, 0), false},
+		{"in the preferences timezone", 22 * 60, 7 * 60, "Europe/Paris", at(21, 30), true},
+		{"unknown timezone falls back to utc", 22 * 60, 7 * 60, "Mars/Olympus", at(21, 30), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := DefaultPreferences("C1")
+			prefs.QuietStart, prefs.QuietEnd, prefs.Timezone = tt.start, tt.end, tt.timezone
+			if got := prefs.InQuietHours(tt.t); got != tt.want {
+				t.Errorf("InQuietHours(%s) = %v, want %v", tt.t.Format(time.Kitchen), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferencesAllows(t *testing.T) {
+	prefs := DefaultPreferences("C1")
+	prefs.DelayAlerts = false
+	prefs.InFlightUpdates = false
+
+	tests := map[string]bool{
+		AlertCategoryGate:     true,
+		AlertCategoryDelay:    false,
+		AlertCategoryTakeoff:  true,
+		AlertCategoryLanding:  true,
+		AlertCategoryInFlight: false,
+		"unknown":             true,
+	}
+	for category, want := range tests {
+		if got := prefs.Allows(category); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", category, got, want)
+		}
+	}
+}
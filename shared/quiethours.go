@@ -0,0 +1,43 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+func HoldAlert(alert HeldAlert, config Config) error {
+	_, err := config.UserDB.Exec(
+		"INSERT OR IGNORE INTO held_alerts (flight_id, alert_type, slack_channel, slack_user_id, blocks, held_at) VALUES (?, ?, ?, ?, ?, ?)",
+		alert.FlightID, alert.AlertType, alert.SlackChannel, alert.SlackUserID, alert.Blocks, alert.HeldAt,
+	)
+	return err
+}
+
+// GetHeldAlerts returns every held alert, oldest first
+func GetHeldAlerts(config Config) ([]HeldAlert, error) {
+	var h HeldAlert
+	cols, _ := structColumns(&h)
+	query := fmt.Sprintf("SELECT %s FROM held_alerts ORDER BY held_at ASC", strings.Join(cols, ", "))
+
+	rows, err := config.UserDB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []HeldAlert
+	for rows.Next() {
+		var a HeldAlert
+		_, dest := structColumns(&a)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func DeleteHeldAlert(flightID, alertType, channel string, config Config) error {
+	_, err := config.UserDB.Exec("DELETE FROM held_alerts WHERE flight_id = ? AND alert_type = ? AND slack_channel = ?", flightID, alertType, channel)
+	return err
+}
@@ -0,0 +1,216 @@
+package shared
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+)
+
+// flights with the same number departing within this window are the same instance
+const flightInstanceWindow = 6 * 60 * 60
+
+type SubscriptionFilter struct {
+	ID                 string
+	FlightID           string
+	FlightNumber       string
+	FlightNumberPrefix string // flight numbers starting with it, for searches
+	SlackChannel       string
+	SlackUserID        string
+}
+
+const subscriptionColumns = "s.id, s.flight_id, s.slack_channel, s.slack_user_id, s.traveler_id, s.created_at, s.last_announced_dep_estimated, s.last_announced_arr_estimated, f.flight_number, f.departure"
+
+func scanSubscription(scanner interface{ Scan(...any) error }) (Subscription, error) {
+	var s Subscription
+	err := scanner.Scan(&s.ID, &s.FlightID, &s.SlackChannel, &s.SlackUserID, &s.TravelerID, &s.CreatedAt, &s.LastAnnouncedDepEstimated, &s.LastAnnouncedArrEstimated, &s.FlightNumber, &s.Departure)
+	return s, err
+}
+
+func GetSubscriptions(filter SubscriptionFilter, config Config) ([]Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions s JOIN flights f ON f.id = s.flight_id WHERE 1=1"
+	args := []any{}
+
+	if filter.ID != "" {
+		query += " AND s.id = ?"
+		args = append(args, filter.ID)
+	}
+	if filter.FlightID != "" {
+		query += " AND s.flight_id = ?"
+		args = append(args, filter.FlightID)
+	}
+	if filter.FlightNumber != "" {
+		query += " AND f.flight_number = ?"
+		args = append(args, filter.FlightNumber)
+	}
+	if filter.FlightNumberPrefix != "" {
+		query += " AND f.flight_number LIKE ?"
+		args = append(args, filter.FlightNumberPrefix+"%")
+	}
+	if filter.SlackChannel != "" {
+		query += " AND s.slack_channel = ?"
+		args = append(args, filter.SlackChannel)
+	}
+	if filter.SlackUserID != "" {
+		query += " AND s.slack_user_id = ?"
+		args = append(args, filter.SlackUserID)
+	}
+	query += " ORDER BY f.departure ASC"
+
+	rows, err := config.UserDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func GetSubscription(id string, config Config) (*Subscription, error) {
+	row := config.UserDB.QueryRow("SELECT "+subscriptionColumns+" FROM subscriptions s JOIN flights f ON f.id = s.flight_id WHERE s.id = ?", id)
+	s, err := scanSubscription(row)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// findFlightInstance returns the tracked flight with the same number departing around the same time
+func findFlightInstance(flightNumber string, departure int64, config Config) (*Flight, error) {
+	row := config.UserDB.QueryRow(
+		"SELECT id, flight_number, slack_channel, slack_user_id, departure FROM flights WHERE flight_number = ? AND ABS(departure - ?) < ? ORDER BY ABS(departure - ?) LIMIT 1",
+		flightNumber, departure, flightInstanceWindow, departure,
+	)
+
+	var f Flight
+	err := row.Scan(&f.ID, &f.FlightNumber, &f.SlackChannel, &f.SlackUserID, &f.Departure)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SubscribeToFlight adds a subscriber to a flight, creating the tracked flight instance if nobody tracks it yet.
+// Only SlackChannel, SlackUserID and TravelerID of sub are used.
+// Subscribing the same channel twice returns the existing subscription.
+func SubscribeToFlight(flightNumber string, departure int64, sub Subscription, config Config) (*Subscription, error) {
+	flightNumber = strings.ToUpper(flightNumber)
+
+	flight, err := findFlightInstance(flightNumber, departure, config)
+	if errors.Is(err, sql.ErrNoRows) {
+		flight = &Flight{
+			ID:           uuid.New().String(),
+			FlightNumber: flightNumber,
+			SlackChannel: sub.SlackChannel,
+			SlackUserID:  sub.SlackUserID,
+			Departure:    departure,
+		}
+		err = RegisterTrackedFlight(*flight, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := GetSubscriptions(SubscriptionFilter{FlightID: flight.ID, SlackChannel: sub.SlackChannel}, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return &existing[0], nil
+	}
+
+	if sub.TravelerID == sub.SlackUserID {
+		sub.TravelerID = ""
+	}
+	sub.ID = uuid.New().String()
+	sub.FlightID = flight.ID
+	sub.CreatedAt = time.Now().Unix()
+	sub.FlightNumber = flight.FlightNumber
+	sub.Departure = flight.Departure
+
+	_, err = config.UserDB.Exec(
+		"INSERT INTO subscriptions (id, flight_id, slack_channel, slack_user_id, traveler_id, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		sub.ID, sub.FlightID, sub.SlackChannel, sub.SlackUserID, sub.TravelerID, sub.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Unsubscribe removes a subscriber, and the flight itself once nobody is subscribed to it anymore
+func Unsubscribe(subscriptionID string, config Config) error {
+	sub, err := GetSubscription(subscriptionID, config)
+	if err != nil {
+		return err
+	}
+
+	_, err = config.UserDB.Exec("DELETE FROM subscriptions WHERE id = ?", subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	var remaining int
+	err = config.UserDB.QueryRow("SELECT COUNT(*) FROM subscriptions WHERE flight_id = ?", sub.FlightID).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+	if err := removeTripLeg(subscriptionID, config); err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return UntrackFlight(sub.FlightID, config)
+	}
+	return nil
+}
+
+func SaveAnnouncedEstimates(sub Subscription, config Config) error {
+	_, err := config.UserDB.Exec(
+		"UPDATE subscriptions SET last_announced_dep_estimated = ?, last_announced_arr_estimated = ? WHERE id = ?",
+		sub.LastAnnouncedDepEstimated, sub.LastAnnouncedArrEstimated, sub.ID,
+	)
+	return err
+}
+
+// IsDM returns whether the alerts are sent by dm (SlackChannel being a user id)
+func (s Subscription) IsDM() bool {
+	return IsUserID(s.SlackChannel)
+}
+
+// Destination returns a slack mention of where the alerts are sent
+func (s Subscription) Destination() string {
+	if s.IsDM() {
+		return "DMs of <@" + s.SlackChannel + ">"
+	}
+	return "<#" + s.SlackChannel + ">"
+}
+
+func IsUserID(id string) bool {
+	return strings.HasPrefix(id, "U") || strings.HasPrefix(id, "W")
+}
+
+// DeliveryChannel returns the conversation to post to, opening a dm with
+// conversations.open when the target is a user
+func DeliveryChannel(target string, config Config) (string, error) {
+	if !IsUserID(target) {
+		return target, nil
+	}
+	channel, _, _, err := config.SlackClient.OpenConversation(&slack.OpenConversationParameters{
+		Users: []string{target},
+	})
+	if err != nil {
+		return "", err
+	}
+	return channel.ID, nil
+}
@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scope used for templates overriding the defaults in the whole workspace
+const WorkspaceScope = "workspace"
+
+func GetAlertTemplate(scopeID, alertType string, config Config) (*AlertTemplate, error) {
+	var t AlertTemplate
+	cols, dest := structColumns(&t)
+	query := fmt.Sprintf("SELECT %s FROM alert_templates WHERE scope_id = ? AND alert_type = ?", strings.Join(cols, ", "))
+
+	err := config.UserDB.QueryRow(query, scopeID, alertType).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func SaveAlertTemplate(t AlertTemplate, config Config) error {
+	_, err := config.UserDB.Exec(`INSERT INTO alert_templates (scope_id, alert_type, body, updated_by, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(scope_id, alert_type) DO UPDATE SET body=excluded.body, updated_by=excluded.updated_by, updated_at=excluded.updated_at`,
+		t.ScopeID, t.AlertType, t.Body, t.UpdatedBy, t.UpdatedAt)
+	return err
+}
+
+func DeleteAlertTemplate(scopeID, alertType string, config Config) error {
+	_, err := config.UserDB.Exec("DELETE FROM alert_templates WHERE scope_id = ? AND alert_type = ?", scopeID, alertType)
+	return err
+}
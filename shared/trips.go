@@ -0,0 +1,241 @@
+package shared
+
+import (
+	"database/sql"
+	"errors"
+	"flight-tracker-slack/flights"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TripFilter struct {
+	ID          string
+	SlackUserID string
+}
+
+type TripLegFilter struct {
+	TripID   string
+	FlightID string
+}
+
+func CreateTrip(trip Trip, config Config) error {
+	cols, _ := structColumns(&trip)
+	query := fmt.Sprintf("INSERT INTO trips (%s) VALUES (%s)", strings.Join(cols, ", "), placeholders(len(cols)))
+	_, err := config.UserDB.Exec(query, structValues(trip)...)
+	return err
+}
+
+func GetTrips(filter TripFilter, config Config) ([]Trip, error) {
+	var t Trip
+	cols, _ := structColumns(&t)
+	query := fmt.Sprintf("SELECT %s FROM trips WHERE 1=1", strings.Join(cols, ", "))
+	args := []any{}
+
+	if filter.ID != "" {
+		query += " AND id = ?"
+		args = append(args, filter.ID)
+	}
+	if filter.SlackUserID != "" {
+		query += " AND slack_user_id = ?"
+		args = append(args, filter.SlackUserID)
+	}
+	query += " ORDER BY created_at ASC"
+
+	rows, err := config.UserDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trips []Trip
+	for rows.Next() {
+		var trip Trip
+		_, dest := structColumns(&trip)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		trips = append(trips, trip)
+	}
+	return trips, rows.Err()
+}
+
+// AddTripLeg adds the flight tracked by a subscription to a trip
+func AddTripLeg(leg TripLeg, config Config) error {
+	cols, _ := structColumns(&leg)
+	query := fmt.Sprintf("INSERT OR REPLACE INTO trip_legs (%s) VALUES (%s)", strings.Join(cols, ", "), placeholders(len(cols)))
+	_, err := config.UserDB.Exec(query, structValues(leg)...)
+	return err
+}
+
+// GetTripLegs returns legs with their subscription, by departure.
+// The legs of flights that aren't tracked anymore come without subscription.
+func GetTripLegs(filter TripLegFilter, config Config) ([]TripLeg, error) {
+	query := "SELECT l.trip_id, l.subscription_id, l.origin, l.destination, l.arrival, l.flight_number, l.departure, l.arrival_terminal, " +
+		"COALESCE(s.id, ''), COALESCE(s.flight_id, ''), COALESCE(s.slack_channel, ''), COALESCE(s.slack_user_id, ''), COALESCE(s.traveler_id, ''), " +
+		"COALESCE(s.created_at, 0), COALESCE(s.last_announced_dep_estimated, 0), COALESCE(s.last_announced_arr_estimated, 0), " +
+		"COALESCE(f.flight_number, l.flight_number), COALESCE(f.departure, l.departure)" +
+		" FROM trip_legs l LEFT JOIN subscriptions s ON s.id = l.subscription_id LEFT JOIN flights f ON f.id = s.flight_id WHERE 1=1"
+	args := []any{}
+
+	if filter.TripID != "" {
+		query += " AND l.trip_id = ?"
+		args = append(args, filter.TripID)
+	}
+	if filter.FlightID != "" {
+		query += " AND s.flight_id = ?"
+		args = append(args, filter.FlightID)
+	}
+	query += " ORDER BY COALESCE(f.departure, l.departure) ASC"
+
+	rows, err := config.UserDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var legs []TripLeg
+	for rows.Next() {
+		var l TripLeg
+		s := &l.Subscription
+		err := rows.Scan(&l.TripID, &l.SubscriptionID, &l.Origin, &l.Destination, &l.Arrival, &l.FlightNumber, &l.Departure, &l.ArrivalTerminal,
+			&s.ID, &s.FlightID, &s.SlackChannel, &s.SlackUserID, &s.TravelerID, &s.CreatedAt, &s.LastAnnouncedDepEstimated, &s.LastAnnouncedArrEstimated, &s.FlightNumber, &s.Departure)
+		if err != nil {
+			return nil, err
+		}
+		legs = append(legs, l)
+	}
+	return legs, rows.Err()
+}
+
+// DeleteTrip removes a trip and its legs, leaving their subscriptions tracked
+func DeleteTrip(tripID string, config Config) error {
+	if _, err := config.UserDB.Exec("DELETE FROM trip_legs WHERE trip_id = ?", tripID); err != nil {
+		return err
+	}
+	_, err := config.UserDB.Exec("DELETE FROM trips WHERE id = ?", tripID)
+	return err
+}
+
+// removeTripLeg removes an untracked subscription from its trip, and the trip once none of it is tracked anymore
+func removeTripLeg(subscriptionID string, config Config) error {
+	if _, err := config.UserDB.Exec("DELETE FROM trip_legs WHERE subscription_id = ?", subscriptionID); err != nil {
+		return err
+	}
+	return pruneTrips(config)
+}
+
+// keepTripLegs copies into the trip legs of a flight what the connection to the next leg needs,
+// before the flight stops being tracked
+func keepTripLegs(flightID string, config Config) error {
+	f, err := GetFlight(flightID, config)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var arrival int64
+	var terminal string
+	if state, err := GetFlightState(flightID, config); err == nil {
+		arrival = firstNonZero(state.ArrActual, state.ArrEstimated, state.ArrScheduled)
+		terminal = state.DestTerminal
+	}
+
+	_, err = config.UserDB.Exec(
+		"UPDATE trip_legs SET flight_number = ?, departure = ?, arrival = CASE WHEN ? != 0 THEN ? ELSE arrival END, arrival_terminal = ?"+
+			" WHERE subscription_id IN (SELECT id FROM subscriptions WHERE flight_id = ?)",
+		f.FlightNumber, f.Departure, arrival, arrival, terminal, flightID,
+	)
+	return err
+}
+
+// pruneTrips removes the legs of untracked flights once no flight of their trip is tracked anymore,
+// then the trips left without legs
+func pruneTrips(config Config) error {
+	_, err := config.UserDB.Exec(
+		"DELETE FROM trip_legs WHERE subscription_id NOT IN (SELECT id FROM subscriptions)" +
+			" AND (departure = 0 OR trip_id NOT IN (SELECT l.trip_id FROM trip_legs l JOIN subscriptions s ON s.id = l.subscription_id))",
+	)
+	if err != nil {
+		return err
+	}
+	_, err = config.UserDB.Exec("DELETE FROM trips WHERE id NOT IN (SELECT trip_id FROM trip_legs)")
+	return err
+}
+
+// Connections returns the changes of plane between the legs of a trip, sorted by departure.
+// state returns the last known state of a flight, nil if it wasn't polled yet.
+func Connections(legs []TripLeg, state func(flightID string) *FlightState) []Connection {
+	var connections []Connection
+	for i := 1; i < len(legs); i++ {
+		inbound, outbound := legs[i-1], legs[i]
+		c := Connection{
+			Airport:   inbound.Destination,
+			Inbound:   inbound,
+			Outbound:  outbound,
+			Arrival:   inbound.Arrival,
+			Departure: outbound.Subscription.Departure,
+			// an untracked outbound flight has flown already
+			Departed:        outbound.Untracked(),
+			ArrivalTerminal: inbound.ArrivalTerminal,
+		}
+
+		// estimates are better than the schedule, which is better than nothing
+		if s := state(inbound.Subscription.FlightID); s != nil {
+			c.Arrival = firstNonZero(s.ArrActual, s.ArrEstimated, s.ArrScheduled, c.Arrival)
+			c.ArrivalTerminal = s.DestTerminal
+		}
+		if s := state(outbound.Subscription.FlightID); s != nil {
+			c.Departure = firstNonZero(s.DepActual, s.DepEstimated, s.DepScheduled, c.Departure)
+			c.Departed = s.DepActual != 0
+			c.DepartureTerminal = s.OriginTerminal
+		}
+		c.MinimumTime = flights.MinimumConnectionTime(c.Airport, c.ArrivalTerminal, c.DepartureTerminal)
+		connections = append(connections, c)
+	}
+	return connections
+}
+
+// Buffer is the time left to change planes, negative when the connection is missed
+func (c Connection) Buffer() time.Duration {
+	return time.Unix(c.Departure, 0).Sub(time.Unix(c.Arrival, 0))
+}
+
+// BufferSeconds is the buffer in seconds, for templates
+func (c Connection) BufferSeconds() int64 {
+	return int64(c.Buffer() / time.Second)
+}
+
+// MinimumSeconds is the minimum connection time in seconds, for templates
+func (c Connection) MinimumSeconds() int64 {
+	return int64(c.MinimumTime / time.Second)
+}
+
+// ConnectionMargin is kept on top of the minimum connection time before a connection stops being tight
+const ConnectionMargin = 30 * time.Minute
+
+// Risk compares the buffer to the minimum connection time of the airport
+func (c Connection) Risk() ConnectionRisk {
+	switch buffer := c.Buffer(); {
+	case c.Arrival == 0:
+		return ConnectionRiskUnknown
+	case buffer < 0:
+		return ConnectionRiskMissed
+	case buffer < c.MinimumTime:
+		return ConnectionRiskHigh
+	case buffer < c.MinimumTime+ConnectionMargin:
+		return ConnectionRiskTight
+	}
+	return ConnectionRiskLow
+}
+
+func firstNonZero(values ...int64) int64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
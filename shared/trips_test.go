@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectionRisk(t *testing.T) {
+	departure := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC).Unix()
+	minutes := func(m int64) int64 { return m * 60 }
+
+	inbound := TripLeg{Destination: "ZZZ", Subscription: Subscription{ID: "in", FlightID: "inbound"}}
+	outbound := TripLeg{Origin: "ZZZ", Subscription: Subscription{ID: "out", FlightID: "outbound", Departure: departure}}
+
+	tests := []struct {
+		name     string
+		inbound  *FlightState
+		outbound *FlightState
+		want     ConnectionRisk
+	}{
+		{"arrival unknown", nil, nil, ConnectionRiskUnknown},
+		{"plenty of time", &FlightState{ArrEstimated: departure - minutes(240)}, nil, ConnectionRiskLow},
+		{"within the margin", &FlightState{ArrEstimated: departure - minutes(80)}, nil, ConnectionRiskTight},
+		{"under the minimum", &FlightState{ArrEstimated: departure - minutes(30)}, nil, ConnectionRiskHigh},
+		{"arrives after the departure", &FlightState{ArrEstimated: departure + minutes(10)}, nil, ConnectionRiskMissed},
+		{"the actual arrival wins", &FlightState{ArrEstimated: departure - minutes(240), ArrActual: departure - minutes(30)}, nil, ConnectionRiskHigh},
+		{"the outbound delay helps", &FlightState{ArrEstimated: departure - minutes(30)}, &FlightState{DepEstimated: departure + minutes(240)}, ConnectionRiskLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := map[string]*FlightState{"inbound": tt.inbound, "outbound": tt.outbound}
+			connections := Connections([]TripLeg{inbound, outbound}, func(id string) *FlightState { return states[id] })
+			if len(connections) != 1 {
+				t.Fatalf("got %d connections, want 1", len(connections))
+			}
+			if got := connections[0].Risk(); got != tt.want {
+				t.Errorf("Risk() = %v, want %v (buffer %s, minimum %s)", got, tt.want, connections[0].Buffer(), connections[0].MinimumTime)
+			}
+		})
+	}
+}
+
+func TestConnectionsOfUntrackedLegs(t *testing.T) {
+	departure := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC).Unix()
+
+	// the inbound flight arrived and isn't tracked anymore, its leg keeping its arrival
+	arrived := TripLeg{Destination: "ZZZ", Arrival: departure - 3600, ArrivalTerminal: "2", FlightNumber: "AF1"}
+	outbound := TripLeg{Origin: "ZZZ", Subscription: Subscription{ID: "out", FlightID: "outbound", Departure: departure}}
+	none := func(string) *FlightState { return nil }
+
+	c := Connections([]TripLeg{arrived, outbound}, none)[0]
+	if c.Arrival != arrived.Arrival || c.ArrivalTerminal != "2" || c.Departed {
+		t.Errorf("got arrival %d in terminal %q, departed %v", c.Arrival, c.ArrivalTerminal, c.Departed)
+	}
+
+	// once the outbound flight isn't tracked either, the connection is over
+	c = Connections([]TripLeg{arrived, {Origin: "ZZZ", Departure: departure}}, none)[0]
+	if !c.Departed {
+		t.Error("a connection to an untracked flight should be over")
+	}
+}
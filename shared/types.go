@@ -15,6 +15,13 @@ type Config struct {
 	TileStore     *maps.TileStore
 	SigningSecret string
 	SlackToken    string
+	PublicURL     string // where slack can reach the bot, e.g. for map thumbnails (optional)
+	SocketMode    bool   // receive slack requests over a websocket instead of http
+	AppToken      string // app-level token, for socket mode
+	AdminToken    string // bearer token of the admin endpoints, disabled when empty
+	AdminAddr     string // where the admin endpoints listen, localhost by default
+
+	AllowPrivateWebhooks bool // lets webhooks reach local and private addresses, for development
 }
 
 type Command struct {
@@ -33,8 +40,16 @@ type NotificationLog struct {
 type Interaction struct {
 	Prefix  string
 	Execute func(callback slack.InteractionCallback, config Config)
+	// optional, for modals needing an answer to their submission (e.g. validation errors)
+	Submit func(callback slack.InteractionCallback, config Config) *slack.ViewSubmissionResponse
+	// optional, for external selects searching as the user types
+	Options func(callback slack.InteractionCallback, config Config) *slack.OptionsResponse
+	// optional, for modals opened with notify_on_close
+	Closed func(callback slack.InteractionCallback, config Config)
 }
 
+// Flight is one tracked flight instance, polled once whatever the number of subscribers.
+// SlackChannel and SlackUserID are the ones of the first subscriber.
 type Flight struct {
 	ID           string `db:"id" json:"id"`
 	FlightNumber string `db:"flight_number" json:"flight_number"`
@@ -44,26 +59,205 @@ type Flight struct {
 }
 
 type FlightState struct {
-	FlightID         string `db:"flight_id"`
-	Status           string `db:"status"`
-	OriginGate       string `db:"origin_gate"`
-	OriginTerminal   string `db:"origin_terminal"`
-	DestGate         string `db:"dest_gate"`
-	DestTerminal     string `db:"dest_terminal"`
-	DepScheduled     int64  `db:"dep_scheduled"`
-	DepEstimated     int64  `db:"dep_estimated"`
-	DepActual        int64  `db:"dep_actual"`
-	TakeOffActual    int64  `db:"takeoff_actual"`
-	TakeOffEstimated int64  `db:"takeoff_estimated"`
-	LandingActual    int64  `db:"landing_actual"`
-	LandingEstimated int64  `db:"landing_estimated"`
-	ArrScheduled     int64  `db:"arr_scheduled"`
-	ArrEstimated     int64  `db:"arr_estimated"`
-	ArrActual        int64  `db:"arr_actual"`
-	Altitude         int    `db:"altitude"`
-	Groundspeed      int    `db:"groundspeed"`
-	UpdatedAt        int64  `db:"updated_at"`
-
-	LastAnnouncedDepEstimated int64 `db:"last_announced_dep_estimated"`
-	LastAnnouncedArrEstimated int64 `db:"last_announced_arr_estimated"`
+	FlightID         string `db:"flight_id" json:"flight_id"`
+	Status           string `db:"status" json:"status"`
+	OriginGate       string `db:"origin_gate" json:"origin_gate"`
+	OriginTerminal   string `db:"origin_terminal" json:"origin_terminal"`
+	DestGate         string `db:"dest_gate" json:"dest_gate"`
+	DestTerminal     string `db:"dest_terminal" json:"dest_terminal"`
+	DestBaggage      string `db:"dest_baggage" json:"dest_baggage"` // baggage claim belt
+	DepScheduled     int64  `db:"dep_scheduled" json:"dep_scheduled"`
+	DepEstimated     int64  `db:"dep_estimated" json:"dep_estimated"`
+	DepActual        int64  `db:"dep_actual" json:"dep_actual"`
+	TakeOffActual    int64  `db:"takeoff_actual" json:"takeoff_actual"`
+	TakeOffEstimated int64  `db:"takeoff_estimated" json:"takeoff_estimated"`
+	LandingActual    int64  `db:"landing_actual" json:"landing_actual"`
+	LandingEstimated int64  `db:"landing_estimated" json:"landing_estimated"`
+	ArrScheduled     int64  `db:"arr_scheduled" json:"arr_scheduled"`
+	ArrEstimated     int64  `db:"arr_estimated" json:"arr_estimated"`
+	ArrActual        int64  `db:"arr_actual" json:"arr_actual"`
+	Altitude         int    `db:"altitude" json:"altitude"`
+	Groundspeed      int    `db:"groundspeed" json:"groundspeed"`
+	UpdatedAt        int64  `db:"updated_at" json:"updated_at"`
+
+	// the aircraft and the previous leg it flies, to see delays coming before the airline announces them
+	Tail                string `db:"tail" json:"tail"`
+	InboundOrigin       string `db:"inbound_origin" json:"inbound_origin"` // iata code
+	InboundArrScheduled int64  `db:"inbound_arr_scheduled" json:"inbound_arr_scheduled"`
+	InboundArrEstimated int64  `db:"inbound_arr_estimated" json:"inbound_arr_estimated"`
+	InboundArrActual    int64  `db:"inbound_arr_actual" json:"inbound_arr_actual"`
+	InboundCheckedAt    int64  `db:"inbound_checked_at" json:"-"` // last lookup of the inbound flight
+}
+
+// Subscription binds a tracked flight to a channel (or a dm) where its alerts are sent.
+// For dms, SlackChannel holds the id of the user to notify.
+type Subscription struct {
+	ID           string `db:"id" json:"id"`
+	FlightID     string `db:"flight_id" json:"flight_id"`
+	SlackChannel string `db:"slack_channel" json:"slack_channel"`
+	SlackUserID  string `db:"slack_user_id" json:"slack_user_id"` // who tracked the flight
+	TravelerID   string `db:"traveler_id" json:"traveler_id"`     // who is flying, empty if it's the tracker
+	CreatedAt    int64  `db:"created_at" json:"created_at"`
+
+	// last estimated times announced to this subscriber, used for the delay threshold
+	LastAnnouncedDepEstimated int64 `db:"last_announced_dep_estimated" json:"-"`
+	LastAnnouncedArrEstimated int64 `db:"last_announced_arr_estimated" json:"-"`
+
+	// joined from the flights table
+	FlightNumber string `json:"flight_number"`
+	Departure    int64  `json:"departure"`
+}
+
+// Preferences holds the notification settings of a channel or a user,
+// ScopeID being either a slack channel id or a slack user id
+type Preferences struct {
+	ScopeID         string `db:"scope_id"`
+	GateAlerts      bool   `db:"gate_alerts"`
+	DelayAlerts     bool   `db:"delay_alerts"`
+	TakeoffAlerts   bool   `db:"takeoff_alerts"`
+	LandingAlerts   bool   `db:"landing_alerts"`
+	InFlightUpdates bool   `db:"in_flight_updates"`
+	DelayThreshold  int64  `db:"delay_threshold"` // in seconds
+	UpdateInterval  int64  `db:"update_interval"` // in seconds
+	QuietStart      int    `db:"quiet_start"`     // minutes after midnight, -1 if disabled
+	QuietEnd        int    `db:"quiet_end"`       // minutes after midnight, -1 if disabled
+	Timezone        string `db:"timezone"`        // slack timezone used for the quiet hours
+	Locale          string `db:"locale"`          // language of the messages, empty to follow the slack user
+}
+
+// HeldAlert is an alert kept during quiet hours, delivered in a summary when they end
+type HeldAlert struct {
+	FlightID     string `db:"flight_id"`
+	AlertType    string `db:"alert_type"`
+	SlackChannel string `db:"slack_channel"`
+	SlackUserID  string `db:"slack_user_id"`
+	Blocks       string `db:"blocks"` // json encoded slack.Blocks
+	HeldAt       int64  `db:"held_at"`
+}
+
+// TrackRequest is what the tracking modal is prefilled with, from the command or a button
+type TrackRequest struct {
+	FlightNumber string `json:"flight"`
+	TravelerID   string `json:"for,omitempty"`
+	DM           bool   `json:"dm,omitempty"`
+	Date         string `json:"date,omitempty"` // departure date, YYYY-MM-DD
+	Origin       string `json:"from,omitempty"` // iata code of the departure airport, narrowing the legs
+}
+
+// AlertTemplate overrides the default template of an alert type for a channel or the whole workspace
+type AlertTemplate struct {
+	ScopeID   string `db:"scope_id"`
+	AlertType string `db:"alert_type"`
+	Body      string `db:"body"`
+	UpdatedBy string `db:"updated_by"`
+	UpdatedAt int64  `db:"updated_at"`
+}
+
+// Webhook is an url receiving the flight events of a channel (or of the whole workspace)
+type Webhook struct {
+	ID        string `db:"id"`
+	ScopeID   string `db:"scope_id"` // slack channel id, or WorkspaceScope
+	URL       string `db:"url"`
+	Secret    string `db:"secret"` // used to sign the payloads
+	CreatedBy string `db:"created_by"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+// WebhookDelivery is one attempt at delivering an event to a webhook
+type WebhookDelivery struct {
+	ID         string `db:"id"`
+	WebhookID  string `db:"webhook_id"`
+	EventID    string `db:"event_id"`
+	EventType  string `db:"event_type"`
+	Attempt    int    `db:"attempt"`
+	StatusCode int    `db:"status_code"` // 0 if the request failed before getting a response
+	Error      string `db:"error"`
+	Duration   int64  `db:"duration"` // in milliseconds
+	CreatedAt  int64  `db:"created_at"`
+
+	// failed attempts keep the payload until they're retried, 0 when no retry is due
+	Body          string `db:"body"`
+	NextAttemptAt int64  `db:"next_attempt_at"`
+}
+
+// Trip groups the flights of a journey with connections, tracked together in the same channel
+type Trip struct {
+	ID           string `db:"id"`
+	SlackChannel string `db:"slack_channel"` // channel or user id, like subscriptions
+	SlackUserID  string `db:"slack_user_id"` // who tracked the trip
+	TravelerID   string `db:"traveler_id"`   // who is flying, empty if it's the tracker
+	CreatedAt    int64  `db:"created_at"`
+}
+
+// TripLeg is one of the flights of a trip, through the subscription tracking it
+type TripLeg struct {
+	TripID         string `db:"trip_id"`
+	SubscriptionID string `db:"subscription_id"`
+	Origin         string `db:"origin"`      // iata code
+	Destination    string `db:"destination"` // iata code
+	Arrival        int64  `db:"arrival"`     // scheduled, until the flight state has an estimate
+
+	// kept once the flight isn't tracked anymore, for the connection to the next leg
+	FlightNumber    string `db:"flight_number"`
+	Departure       int64  `db:"departure"`
+	ArrivalTerminal string `db:"arrival_terminal"`
+
+	// joined from the subscriptions and flights tables, empty once the flight isn't tracked
+	Subscription Subscription
+}
+
+// Untracked tells whether the flight of the leg isn't tracked anymore, usually because it arrived
+func (l TripLeg) Untracked() bool {
+	return l.Subscription.ID == ""
+}
+
+// Connection is a change of plane between two legs of a trip
+type Connection struct {
+	Airport           string // iata code
+	Inbound           TripLeg
+	Outbound          TripLeg
+	Arrival           int64         // best known arrival of the inbound flight, 0 if unknown
+	Departure         int64         // best known departure of the outbound flight
+	Departed          bool          // the outbound flight left the gate, so the connection is over
+	ArrivalTerminal   string        // terminal of the inbound flight, empty if unknown
+	DepartureTerminal string        // terminal of the outbound flight, empty if unknown
+	MinimumTime       time.Duration // needed to change planes at the airport, between these terminals
+}
+
+// ConnectionRisk tells how likely a connection is to be missed, from the buffer left compared to the minimum connection time
+type ConnectionRisk int
+
+const (
+	ConnectionRiskUnknown ConnectionRisk = iota // the inbound arrival isn't known yet
+	ConnectionRiskLow
+	ConnectionRiskTight  // less than the minimum plus a margin
+	ConnectionRiskHigh   // less than the minimum connection time
+	ConnectionRiskMissed // the outbound flight leaves before the inbound one arrives
+)
+
+// OutboxMessage is a slack message waiting to be delivered by the sender, retried until it goes through
+type OutboxMessage struct {
+	ID             string `db:"id"`
+	IdempotencyKey string `db:"idempotency_key"` // a message is only queued once per key
+	FlightID       string `db:"flight_id"`
+	SubscriptionID string `db:"subscription_id"`
+	AlertType      string `db:"alert_type"`
+	SlackChannel   string `db:"slack_channel"` // channel or user id, resolved with DeliveryChannel when sending
+	Blocks         string `db:"blocks"`        // json encoded slack.Blocks
+	Image          []byte `db:"image"`         // png uploaded with the blocks, if any
+	Title          string `db:"title"`         // title of the uploaded image
+	Status         string `db:"status"`
+	Attempts       int    `db:"attempts"`
+	NextAttemptAt  int64  `db:"next_attempt_at"`
+	LastError      string `db:"last_error"`
+	CreatedAt      int64  `db:"created_at"`
+	SentAt         int64  `db:"sent_at"`
+}
+
+// OutboxBacklog counts the undelivered messages of a channel
+type OutboxBacklog struct {
+	Channel       string `json:"channel"`
+	Pending       int    `json:"pending"`
+	Dead          int    `json:"dead"`
+	OldestPending int64  `json:"oldest_pending"` // created_at of the oldest pending message, 0 if none
 }
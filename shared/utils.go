@@ -2,6 +2,7 @@ package shared
 
 import (
 	"flight-tracker-slack/flights"
+	"flight-tracker-slack/i18n"
 	"fmt"
 	"math"
 	"strings"
@@ -10,8 +11,11 @@ import (
 	"github.com/slack-go/slack"
 )
 
-func NewErrorBlocks(err error, customMessage ...string) []slack.Block {
-	message := "Something wrong happened :x:"
+// slack refuses messages with more than 50 blocks
+const MaxBlocksPerMessage = 50
+
+func NewErrorBlocks(locale string, err error, customMessage ...string) []slack.Block {
+	message := i18n.T(locale, "error.generic")
 	if len(customMessage) > 0 && customMessage[0] != "" {
 		message = customMessage[0]
 	}
@@ -23,13 +27,25 @@ func NewErrorBlocks(err error, customMessage ...string) []slack.Block {
 			nil,
 		),
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("_Error details:_ ```%v```", err), false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(locale, "error.details", err), false, false),
 			nil,
 			nil,
 		),
 	}
 }
 
+// SlackDate returns a <!date> token, slack formats it in the timezone of whoever reads the message
+func SlackDate(t time.Time, format string, fallback string) string {
+	return fmt.Sprintf("<!date^%d^%s|%s>", t.Unix(), format, fallback)
+}
+
+// FormatAirportTime formats a time in the airport timezone, followed by the same time for the reader.
+// A date token is used rather than the tz of the subscriber, as everyone in a channel can be somewhere else.
+func FormatAirportTime(locale string, t time.Time, loc *time.Location) string {
+	fallback := i18n.FormatTime(locale, t.UTC()) + " UTC"
+	return i18n.T(locale, "format.airport_time", i18n.FormatTime(locale, t.In(loc)), SlackDate(t, "{time}", fallback))
+}
+
 func safeUnix(t time.Time) int64 {
 	if t.IsZero() {
 		return 0
@@ -45,7 +61,10 @@ func FlightDetailsToFlightState(details *flights.FlightDetail, id string) Flight
 		FlightID:         id,
 		Status:           details.FlightStatus,
 		OriginGate:       details.Origin.Gate,
+		OriginTerminal:   details.Origin.Terminal,
 		DestGate:         details.Destination.Gate,
+		DestTerminal:     details.Destination.Terminal,
+		DestBaggage:      details.Destination.BaggageClaim(),
 		DepScheduled:     safeUnix(schedule.DepartureScheduled),
 		DepEstimated:     safeUnix(schedule.DepartureEstimated),
 		DepActual:        safeUnix(schedule.DepartureActual),
@@ -55,7 +74,30 @@ func FlightDetailsToFlightState(details *flights.FlightDetail, id string) Flight
 		ArrScheduled:     safeUnix(schedule.ArrivalScheduled),
 		ArrEstimated:     safeUnix(schedule.ArrivalEstimated),
 		ArrActual:        safeUnix(schedule.ArrivalActual),
+		Tail:             details.Aircraft.Tail,
+	}
+}
+
+// SetInboundFlight sets the previous leg flown by the aircraft in the state of a flight
+func SetInboundFlight(state *FlightState, inbound *flights.FlightDetail) {
+	schedule := inbound.GetSchedule()
+	state.InboundOrigin = inbound.Origin.Iata
+	state.InboundArrScheduled = safeUnix(schedule.ArrivalScheduled)
+	state.InboundArrEstimated = safeUnix(schedule.ArrivalEstimated)
+	state.InboundArrActual = safeUnix(schedule.ArrivalActual)
+}
+
+// InboundArrival is the best known arrival of the aircraft at the origin, 0 if there's no inbound flight
+func (s FlightState) InboundArrival() int64 {
+	return firstNonZero(s.InboundArrActual, s.InboundArrEstimated, s.InboundArrScheduled)
+}
+
+// InboundDelay is how late the aircraft arrives at the origin, in seconds
+func (s FlightState) InboundDelay() int64 {
+	if s.InboundArrScheduled == 0 {
+		return 0
 	}
+	return s.InboundArrival() - s.InboundArrScheduled
 }
 
 func FormatDuration(d time.Duration) string {
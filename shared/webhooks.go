@@ -0,0 +1,127 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+type WebhookFilter struct {
+	ID      string
+	ScopeID string
+}
+
+func CreateWebhook(hook Webhook, config Config) error {
+	_, err := config.UserDB.Exec(
+		"INSERT INTO webhooks (id, scope_id, url, secret, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		hook.ID, hook.ScopeID, hook.URL, hook.Secret, hook.CreatedBy, hook.CreatedAt,
+	)
+	return err
+}
+
+func GetWebhooks(filter WebhookFilter, config Config) ([]Webhook, error) {
+	var h Webhook
+	cols, _ := structColumns(&h)
+	query := fmt.Sprintf("SELECT %s FROM webhooks WHERE 1=1", strings.Join(cols, ", "))
+	args := []any{}
+
+	if filter.ID != "" {
+		query += " AND id = ?"
+		args = append(args, filter.ID)
+	}
+	if filter.ScopeID != "" {
+		query += " AND scope_id = ?"
+		args = append(args, filter.ScopeID)
+	}
+	query += " ORDER BY created_at ASC"
+
+	rows, err := config.UserDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var hook Webhook
+		_, dest := structColumns(&hook)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook of a scope and its delivery log, returning whether it existed
+func DeleteWebhook(id, scopeID string, config Config) (bool, error) {
+	res, err := config.UserDB.Exec("DELETE FROM webhooks WHERE id = ? AND scope_id = ?", id, scopeID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	_, err = config.UserDB.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id)
+	return true, err
+}
+
+func LogWebhookDelivery(d WebhookDelivery, config Config) error {
+	cols, _ := structColumns(&d)
+	query := fmt.Sprintf("INSERT INTO webhook_deliveries (%s) VALUES (%s)", strings.Join(cols, ", "), placeholders(len(cols)))
+	_, err := config.UserDB.Exec(query, structValues(d)...)
+	return err
+}
+
+// GetWebhookDeliveries returns the last delivery attempts of a webhook, newest first
+func GetWebhookDeliveries(webhookID string, limit int, config Config) ([]WebhookDelivery, error) {
+	var d WebhookDelivery
+	cols, _ := structColumns(&d)
+	query := fmt.Sprintf("SELECT %s FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC, attempt DESC LIMIT ?", strings.Join(cols, ", "))
+
+	rows, err := config.UserDB.Query(query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		_, dest := structColumns(&delivery)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDueWebhookRetries returns the failed deliveries whose retry is due, oldest first
+func GetDueWebhookRetries(now int64, limit int, config Config) ([]WebhookDelivery, error) {
+	var d WebhookDelivery
+	cols, _ := structColumns(&d)
+	query := fmt.Sprintf("SELECT %s FROM webhook_deliveries WHERE next_attempt_at > 0 AND next_attempt_at <= ? ORDER BY next_attempt_at ASC LIMIT ?", strings.Join(cols, ", "))
+
+	rows, err := config.UserDB.Query(query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		_, dest := structColumns(&delivery)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClearWebhookRetry marks a failed delivery as handled, its retry being logged as a new attempt
+func ClearWebhookRetry(id string, config Config) error {
+	_, err := config.UserDB.Exec("UPDATE webhook_deliveries SET next_attempt_at = 0, body = '' WHERE id = ?", id)
+	return err
+}
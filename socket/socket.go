@@ -0,0 +1,72 @@
+package socket
+
+import (
+	"flight-tracker-slack/commands"
+	"flight-tracker-slack/eventsapi"
+	"flight-tracker-slack/interactivity"
+	"flight-tracker-slack/shared"
+	"log"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// Run receives slash commands, interactions and events over a websocket instead of http,
+// for bots without a public url. Everything is dispatched to the same handlers.
+func Run(config shared.Config) error {
+	api := slack.New(config.SlackToken, slack.OptionAppLevelToken(config.AppToken))
+	client := socketmode.New(api)
+
+	go handle(client, config)
+	return client.Run()
+}
+
+func handle(client *socketmode.Client, config shared.Config) {
+	for evt := range client.Events {
+		switch evt.Type {
+		case socketmode.EventTypeConnecting:
+			log.Println("Connecting to slack with socket mode...")
+		case socketmode.EventTypeConnected:
+			log.Println("Connected to slack with socket mode!")
+		case socketmode.EventTypeConnectionError:
+			log.Println("Socket mode connection failed, retrying...")
+
+		case socketmode.EventTypeSlashCommand:
+			cmd, ok := evt.Data.(slack.SlashCommand)
+			if !ok {
+				client.Ack(*evt.Request)
+				continue
+			}
+			client.Ack(*evt.Request)
+			log.Println("Received command: " + cmd.Command)
+			go commands.RunCommand(strings.TrimPrefix(cmd.Command, "/"), cmd, config)
+
+		case socketmode.EventTypeInteractive:
+			payload, ok := evt.Data.(slack.InteractionCallback)
+			if !ok {
+				client.Ack(*evt.Request)
+				continue
+			}
+			// view submissions may answer with validation errors and external selects with their options, in the ack,
+			// so each one is handled apart to keep a slow handler from holding the others
+			go func(request socketmode.Request) {
+				if response := interactivity.DispatchInteraction(payload, config); response != nil {
+					client.Ack(request, response)
+				} else {
+					client.Ack(request)
+				}
+			}(*evt.Request)
+
+		case socketmode.EventTypeEventsAPI:
+			event, ok := evt.Data.(slackevents.EventsAPIEvent)
+			if !ok {
+				client.Ack(*evt.Request)
+				continue
+			}
+			client.Ack(*evt.Request)
+			go eventsapi.DispatchEvent(event, config)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package templates
+
+import (
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"time"
+)
+
+// SampleData returns a made up flight in the middle of its journey, used to preview templates
+func SampleData(now time.Time) AlertData {
+	now = now.Truncate(time.Minute)
+	departure := now.Add(-3 * time.Hour)
+
+	flight := shared.Flight{
+		ID:           "00000000-0000-0000-0000-000000000000",
+		FlightNumber: "AF102",
+		SlackChannel: "C0000000000",
+		SlackUserID:  "U0000000000",
+		Departure:    departure.Unix(),
+	}
+
+	prev := shared.FlightState{
+		FlightID:            flight.ID,
+		Status:              "En Route",
+		OriginGate:          "K42",
+		OriginTerminal:      "2E",
+		DestGate:            "B12",
+		DestTerminal:        "1",
+		DepScheduled:        departure.Unix(),
+		DepEstimated:        departure.Unix(),
+		TakeOffEstimated:    departure.Add(20 * time.Minute).Unix(),
+		LandingEstimated:    departure.Add(8 * time.Hour).Unix(),
+		ArrScheduled:        departure.Add(8*time.Hour + 10*time.Minute).Unix(),
+		ArrEstimated:        departure.Add(8*time.Hour + 10*time.Minute).Unix(),
+		Tail:                "F-GSQA",
+		InboundOrigin:       "MAD",
+		InboundArrScheduled: departure.Add(-90 * time.Minute).Unix(),
+		InboundArrEstimated: departure.Add(-50 * time.Minute).Unix(),
+		InboundArrActual:    departure.Add(-45 * time.Minute).Unix(),
+	}
+
+	curr := prev
+	curr.OriginGate = "K46"
+	curr.DestGate = "B14"
+	curr.OriginTerminal = "2F"
+	curr.DestTerminal = "4"
+	curr.DestBaggage = "7"
+	curr.DepEstimated = departure.Add(25 * time.Minute).Unix()
+	curr.DepActual = departure.Add(25 * time.Minute).Unix()
+	curr.TakeOffEstimated = departure.Add(40 * time.Minute).Unix()
+	curr.TakeOffActual = departure.Add(45 * time.Minute).Unix()
+	curr.LandingActual = departure.Add(8*time.Hour + 20*time.Minute).Unix()
+	curr.ArrEstimated = departure.Add(8*time.Hour + 35*time.Minute).Unix()
+	curr.ArrActual = departure.Add(8*time.Hour + 30*time.Minute).Unix()
+	curr.Altitude = 370
+	curr.Groundspeed = 480
+
+	return AlertData{
+		Flight: flight,
+		Subscription: shared.Subscription{
+			ID:           flight.ID,
+			FlightID:     flight.ID,
+			SlackChannel: flight.SlackChannel,
+			SlackUserID:  flight.SlackUserID,
+			FlightNumber: flight.FlightNumber,
+			Departure:    flight.Departure,
+		},
+		State:    curr,
+		Prev:     prev,
+		Previous: prev.DepEstimated,
+		Connection: shared.Connection{
+			Airport:           "JFK",
+			Inbound:           shared.TripLeg{Origin: "CDG", Destination: "JFK", Subscription: shared.Subscription{FlightNumber: flight.FlightNumber}},
+			Outbound:          shared.TripLeg{Origin: "JFK", Destination: "SFO", Subscription: shared.Subscription{FlightNumber: "AF3652"}},
+			Arrival:           curr.ArrEstimated,
+			Departure:         curr.ArrEstimated + int64(45*time.Minute/time.Second),
+			ArrivalTerminal:   curr.DestTerminal,
+			DepartureTerminal: "8",
+			MinimumTime:       2 * time.Hour,
+		},
+		DepLoc:  LoadLocation(":Europe/Paris"),
+		DestLoc: LoadLocation(":America/New_York"),
+		Now:     now,
+		Locale:  i18n.DefaultLocale,
+	}
+}
+
+// SampleDataFor returns the sample data with the previous estimate matching the alert type
+func SampleDataFor(alertType string, now time.Time) AlertData {
+	data := SampleData(now)
+	if alertType == "arrival_time_change" {
+		data.Previous = data.Prev.ArrEstimated
+	}
+	if alertType == "connection_missed" {
+		data.Connection.Departure = data.Connection.Arrival - int64(20*time.Minute/time.Second)
+	}
+	return data
+}
@@ -0,0 +1,201 @@
+package templates
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flight-tracker-slack/assets"
+	"flight-tracker-slack/i18n"
+	"flight-tracker-slack/shared"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// alert types with a template, in the order they usually happen
+var AlertTypes = []string{
+	"departure_gate_announced",
+	"gate_change",
+	"terminal_change",
+	"inbound_aircraft_late",
+	"departure_time_change",
+	"flight_departed_from_gate",
+	"flight_takeoff",
+	"in_flight_update",
+	"arrival_time_change",
+	"arrival_gate_change",
+	"arrival_terminal_change",
+	"flight_landed",
+	"flight_arrived_at_gate",
+	"baggage_claim",
+	"connection_tight",
+	"connection_at_risk",
+	"connection_missed",
+}
+
+const templatesPath = "templates/alerts/"
+
+var defaults = make(map[string]*template.Template)
+var defaultBodies = make(map[string]string)
+
+// helpers available in every template, on top of the AlertData methods
+var funcs = template.FuncMap{
+	// escapes a value to be put inside a json string
+	"esc": func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b[1 : len(b)-1])
+	},
+	"duration": func(seconds int64) string {
+		return shared.FormatDuration(time.Duration(seconds) * time.Second)
+	},
+	"sub": func(a, b int64) int64 {
+		return a - b
+	},
+}
+
+// AlertData is what templates are rendered against
+type AlertData struct {
+	Flight       shared.Flight
+	Subscription shared.Subscription
+	State        shared.FlightState
+	Prev         shared.FlightState
+	Previous     int64             // previously announced estimate, for time changes
+	Connection   shared.Connection // connection alerts only
+	DepLoc       *time.Location
+	DestLoc      *time.Location
+	Now          time.Time
+	Locale       string
+}
+
+// T translates a catalog key in the language of the subscriber
+func (d AlertData) T(key string, args ...any) string {
+	return i18n.T(d.Locale, key, args...)
+}
+
+// DepTime formats a unix time in the departure airport timezone, and in the timezone of the reader
+func (d AlertData) DepTime(unix int64) string {
+	return shared.FormatAirportTime(d.Locale, time.Unix(unix, 0), d.DepLoc)
+}
+
+// ArrTime formats a unix time in the destination airport timezone, and in the timezone of the reader
+func (d AlertData) ArrTime(unix int64) string {
+	return shared.FormatAirportTime(d.Locale, time.Unix(unix, 0), d.DestLoc)
+}
+
+// Duration formats a number of seconds in the language of the subscriber
+func (d AlertData) Duration(seconds int64) string {
+	return i18n.FormatDuration(d.Locale, time.Duration(seconds)*time.Second)
+}
+
+func (d AlertData) Progress() string {
+	elapsed := d.Now.Sub(time.Unix(d.State.DepActual, 0)).Seconds()
+	return shared.GenerateProgressBar(10, 100*elapsed/float64(d.State.ArrEstimated-d.State.DepActual))
+}
+
+func (d AlertData) TimeLeft() string {
+	return i18n.FormatDuration(d.Locale, time.Unix(d.State.ArrEstimated, 0).Sub(d.Now))
+}
+
+func init() {
+	for _, alertType := range AlertTypes {
+		body, err := assets.Files.ReadFile(templatesPath + alertType + ".tmpl")
+		if err != nil {
+			panic(err)
+		}
+		t, err := Parse(alertType, string(body))
+		if err != nil {
+			panic(err)
+		}
+		defaults[alertType] = t
+		defaultBodies[alertType] = string(body)
+	}
+}
+
+func IsAlertType(alertType string) bool {
+	_, ok := defaults[alertType]
+	return ok
+}
+
+func DefaultBody(alertType string) string {
+	return defaultBodies[alertType]
+}
+
+func Parse(name, body string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(body)
+}
+
+// Execute renders a template and parses the result as block kit json
+func Execute(t *template.Template, data AlertData) ([]slack.Block, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	var blocks slack.Blocks
+	if err := json.Unmarshal(buf.Bytes(), &blocks); err != nil {
+		return nil, fmt.Errorf("template did not produce valid block kit json: %w", err)
+	}
+	if len(blocks.BlockSet) == 0 {
+		return nil, errors.New("template produced no blocks")
+	}
+	return blocks.BlockSet, nil
+}
+
+// Validate checks that a template body parses and renders against the sample data
+func Validate(alertType, body string) error {
+	t, err := Parse(alertType, body)
+	if err != nil {
+		return err
+	}
+	_, err = Execute(t, SampleDataFor(alertType, time.Now()))
+	return err
+}
+
+// Render renders an alert for a channel, using the channel template, then the workspace one, then the default.
+// Broken overrides are logged and skipped.
+func Render(alertType string, channelID string, data AlertData, config shared.Config) ([]slack.Block, error) {
+	for _, scope := range []string{channelID, shared.WorkspaceScope} {
+		if scope == "" {
+			continue
+		}
+		override, err := shared.GetAlertTemplate(scope, alertType, config)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error loading %s template for %s: %v", alertType, scope, err)
+			continue
+		}
+
+		t, err := Parse(alertType, override.Body)
+		if err == nil {
+			var blocks []slack.Block
+			blocks, err = Execute(t, data)
+			if err == nil {
+				return blocks, nil
+			}
+		}
+		log.Printf("Error rendering %s template for %s, falling back: %v", alertType, scope, err)
+	}
+
+	t, ok := defaults[alertType]
+	if !ok {
+		return nil, fmt.Errorf("unknown alert type %q", alertType)
+	}
+	return Execute(t, data)
+}
+
+// LoadLocation loads an airport timezone as given by the upstream payload (e.g. ":Europe/Paris")
+func LoadLocation(tz string) *time.Location {
+	loc, err := time.LoadLocation(strings.TrimPrefix(tz, ":"))
+	if err != nil {
+		log.Printf("Error loading timezone %q: %v\n", tz, err)
+		return time.UTC
+	}
+	return loc
+}
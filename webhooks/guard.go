@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"flight-tracker-slack/shared"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL = errors.New("invalid webhook url")
+	// webhooks can't reach the network of the bot (loopback, private ranges, cloud metadata...)
+	ErrPrivateAddress = errors.New("webhook address is not public")
+)
+
+// carrier-grade nat, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// client refuses to connect to non public addresses, whatever the url resolves to when delivering
+// (so a hostname can't be pointed to an internal address after the webhook was added, nor redirect there)
+var client = &http.Client{
+	Timeout: deliveryTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: deliveryTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: deliveryTimeout,
+	},
+}
+
+// devClient reaches any address, for local receivers when AllowPrivateWebhooks is set
+var devClient = &http.Client{Timeout: deliveryTimeout}
+
+func httpClient(config shared.Config) *http.Client {
+	if config.AllowPrivateWebhooks {
+		return devClient
+	}
+	return client
+}
+
+// ValidateURL checks that a webhook url is http(s) and, unless private webhooks are allowed,
+// that its host only resolves to public addresses
+func ValidateURL(rawURL string, config shared.Config) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, ErrInvalidURL
+	}
+	if config.AllowPrivateWebhooks {
+		return u, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	for _, ip := range ips {
+		if !publicIP(ip.IP) {
+			return nil, ErrPrivateAddress
+		}
+	}
+	return u, nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"errors"
+	"flight-tracker-slack/shared"
+	"net"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("publicIP(%s) = %t, want %t", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		private bool
+		wantErr error
+	}{
+		{url: "https://93.184.216.34/hook"},
+		{url: "http://[2606:2800:220:1:248:1893:25c8:1946]:8080/hook"},
+		{url: "http://127.0.0.1:8080/hook", wantErr: ErrPrivateAddress},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: ErrPrivateAddress},
+		{url: "http://[::1]/hook", wantErr: ErrPrivateAddress},
+		{url: "http://127.0.0.1:8080/hook", private: true},
+		{url: "ftp://93.184.216.34/hook", wantErr: ErrInvalidURL},
+		{url: "file:///etc/passwd", wantErr: ErrInvalidURL},
+		{url: "not a url", wantErr: ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		_, err := ValidateURL(tt.url, shared.Config{AllowPrivateWebhooks: tt.private})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ValidateURL(%s) = %v, want %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
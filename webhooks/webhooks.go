@@ -0,0 +1,270 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flight-tracker-slack/events"
+	"flight-tracker-slack/shared"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sent by "/flight-webhooks test", flight events being named after their events.Type
+const EventTest = "webhook.test"
+
+// headers of every delivery
+const (
+	HeaderEvent     = "X-Flight-Event"
+	HeaderEventID   = "X-Flight-Event-Id"
+	HeaderTimestamp = "X-Flight-Timestamp"
+	HeaderSignature = "X-Flight-Signature"
+)
+
+// deliveries are retried after these delays, the first attempt being immediate
+var retryDelays = []time.Duration{10 * time.Second, 1 * time.Minute, 5 * time.Minute}
+
+// how often the due retries are looked for, and how many at once
+const (
+	retryPollEvery = 5 * time.Second
+	retryBatchSize = 100
+)
+
+// receivers have this long to answer
+const deliveryTimeout = 10 * time.Second
+
+// signatures older than this are rejected by Verify, to prevent replays
+const maxSignatureAge = 5 * time.Minute
+
+// Event is the json body POSTed to webhooks
+type Event struct {
+	ID         string              `json:"id"`
+	Type       string              `json:"type"`
+	CreatedAt  int64               `json:"created_at"`
+	Flight     Flight              `json:"flight"`
+	State      *shared.FlightState `json:"state,omitempty"`
+	Previous   *shared.FlightState `json:"previous,omitempty"`
+	Connection *Connection         `json:"connection,omitempty"` // connection.* events only, the flight being the outbound one
+}
+
+// Flight is the flight of an event, without the slack channel and user that tracked it
+type Flight struct {
+	ID           string `json:"id"`
+	FlightNumber string `json:"flight_number"`
+	Departure    int64  `json:"departure"`
+}
+
+// Connection is the change of plane of a connection event
+type Connection struct {
+	Airport        string `json:"airport"`        // iata code
+	InboundFlight  string `json:"inbound_flight"` // flight number
+	Arrival        int64  `json:"arrival"`        // best known arrival of the inbound flight
+	Departure      int64  `json:"departure"`      // best known departure of the outbound flight
+	BufferSeconds  int64  `json:"buffer_seconds"`
+	MinimumSeconds int64  `json:"minimum_seconds"` // minimum connection time of the airport
+}
+
+func NewEvent(eventType string, f shared.Flight, prev *shared.FlightState, curr *shared.FlightState) Event {
+	return Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().Unix(),
+		Flight:    Flight{ID: f.ID, FlightNumber: f.FlightNumber, Departure: f.Departure},
+		State:     curr,
+		Previous:  prev,
+	}
+}
+
+// Sink dispatches the flight-wide events of the bus to the webhooks of the channels following the flight
+func Sink(config shared.Config) events.Handler {
+	return func(e events.FlightEvent) {
+		if e.Subscription != nil {
+			return
+		}
+		prev, curr := e.Prev, e.State
+		event := NewEvent(string(e.Type), e.Flight, &prev, &curr)
+		event.ID = e.ID
+		event.CreatedAt = e.CreatedAt.Unix()
+		if c := e.Connection; c != nil {
+			event.Connection = &Connection{
+				Airport:        c.Airport,
+				InboundFlight:  c.Inbound.Subscription.FlightNumber,
+				Arrival:        c.Arrival,
+				Departure:      c.Departure,
+				BufferSeconds:  c.BufferSeconds(),
+				MinimumSeconds: c.MinimumSeconds(),
+			}
+		}
+		Dispatch(event, e.Channels, config)
+	}
+}
+
+// NewSecret generates the secret of a new webhook
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature of a payload, like slack does for its requests:
+// "v1=" followed by the hex hmac-sha256 of "v1:<timestamp>:<body>" with the webhook secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v1:%d:", timestamp)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery, for receivers written in go
+func Verify(secret string, header http.Header, body []byte) error {
+	timestamp, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return fmt.Errorf("timestamp too old (%s)", age)
+	}
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(header.Get(HeaderSignature))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// Dispatch sends an event to the webhooks of the given channels and of the workspace.
+// Deliveries run in the background, each webhook getting the event at most once.
+func Dispatch(event Event, channels []string, config shared.Config) {
+	seen := make(map[string]bool)
+	for _, scope := range append(channels, shared.WorkspaceScope) {
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+
+		hooks, err := shared.GetWebhooks(shared.WebhookFilter{ScopeID: scope}, config)
+		if err != nil {
+			log.Printf("Error loading webhooks of %s: %v", scope, err)
+			continue
+		}
+		for _, hook := range hooks {
+			go Deliver(hook, event, config)
+		}
+	}
+}
+
+// Deliver POSTs an event to a webhook. On errors and non 2xx answers, the attempt is stored
+// with the payload and retried by RunRetries, so retries survive restarts.
+// Every attempt is logged in webhook_deliveries.
+func Deliver(hook shared.Webhook, event Event, config shared.Config) bool {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding event %s: %v", event.ID, err)
+		return false
+	}
+	return deliverAttempt(hook, event.ID, event.Type, body, 1, config).Error == ""
+}
+
+// RunRetries delivers the failed events again once their retry is due, until the process stops
+func RunRetries(config shared.Config) {
+	ticker := time.NewTicker(retryPollEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		retries, err := shared.GetDueWebhookRetries(time.Now().Unix(), retryBatchSize, config)
+		if err != nil {
+			log.Println("Error loading webhook retries:", err)
+			continue
+		}
+		for _, d := range retries {
+			// cleared first, so a crash mid-delivery doesn't retry it twice
+			if err := shared.ClearWebhookRetry(d.ID, config); err != nil {
+				log.Printf("Error clearing retry of %s: %v", d.ID, err)
+				continue
+			}
+			hooks, err := shared.GetWebhooks(shared.WebhookFilter{ID: d.WebhookID}, config)
+			if err != nil || len(hooks) == 0 {
+				continue
+			}
+			deliverAttempt(hooks[0], d.EventID, d.EventType, []byte(d.Body), d.Attempt+1, config)
+		}
+	}
+}
+
+// DeliverOnce POSTs an event to a webhook without retrying, e.g. to test it
+func DeliverOnce(hook shared.Webhook, event Event, config shared.Config) shared.WebhookDelivery {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return shared.WebhookDelivery{WebhookID: hook.ID, EventID: event.ID, EventType: event.Type, Error: err.Error()}
+	}
+	delivery := attemptDelivery(hook, event.ID, event.Type, body, config)
+	delivery.Attempt = 1
+	if err := shared.LogWebhookDelivery(delivery, config); err != nil {
+		log.Printf("Error logging delivery of %s to webhook %s: %v", event.ID, hook.ID, err)
+	}
+	return delivery
+}
+
+// deliverAttempt makes one attempt and logs it, scheduling the next one if it failed and attempts are left
+func deliverAttempt(hook shared.Webhook, eventID string, eventType string, body []byte, attempt int, config shared.Config) shared.WebhookDelivery {
+	delivery := attemptDelivery(hook, eventID, eventType, body, config)
+	delivery.Attempt = attempt
+	if delivery.Error != "" {
+		if attempt <= len(retryDelays) {
+			delivery.Body = string(body)
+			delivery.NextAttemptAt = time.Now().Add(retryDelays[attempt-1]).Unix()
+		}
+		log.Printf("Delivery of %s to webhook %s failed (attempt %d): %s", eventID, hook.ID, attempt, delivery.Error)
+	}
+	if err := shared.LogWebhookDelivery(delivery, config); err != nil {
+		log.Printf("Error logging delivery of %s to webhook %s: %v", eventID, hook.ID, err)
+	}
+	return delivery
+}
+
+func attemptDelivery(hook shared.Webhook, eventID string, eventType string, body []byte, config shared.Config) shared.WebhookDelivery {
+	start := time.Now()
+	delivery := shared.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: hook.ID,
+		EventID:   eventID,
+		EventType: eventType,
+		CreatedAt: start.Unix(),
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "flight-tracker-slack")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderEventID, eventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, start.Unix(), body))
+
+	resp, err := httpClient(config).Do(req)
+	delivery.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		delivery.Error = resp.Status
+	}
+	return delivery
+}
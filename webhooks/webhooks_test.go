@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"flight-tracker-slack/shared"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1","type":"flight.departed"}`)
+	signed := func(secret string, timestamp time.Time, body []byte) http.Header {
+		header := http.Header{}
+		header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+		header.Set(HeaderSignature, Sign(secret, timestamp.Unix(), body))
+		return header
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		wantErr bool
+	}{
+		{name: "valid", header: signed("secret", time.Now(), body), body: body},
+		{name: "tampered body", header: signed("secret", time.Now(), body), body: []byte(`{"id":"2"}`), wantErr: true},
+		{name: "other secret", header: signed("other", time.Now(), body), body: body, wantErr: true},
+		{name: "replayed", header: signed("secret", time.Now().Add(-10*time.Minute), body), body: body, wantErr: true},
+		{name: "from the future", header: signed("secret", time.Now().Add(10*time.Minute), body), body: body, wantErr: true},
+		{name: "unsigned", header: http.Header{}, body: body, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify("secret", tt.header, tt.body); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEventWithoutSlackIDs(t *testing.T) {
+	f := shared.Flight{ID: "flight", FlightNumber: "AF123", SlackChannel: "C123", SlackUserID: "U123", Departure: 1748779200}
+	body, err := json.Marshal(NewEvent("flight.departed", f, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "C123") || strings.Contains(string(body), "U123") {
+		t.Errorf("got payload %s, want it without the slack channel and user", body)
+	}
+	if !strings.Contains(string(body), `"flight_number":"AF123"`) {
+		t.Errorf("got payload %s, want the flight number", body)
+	}
+}